@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"hash"
 	"io"
@@ -20,10 +21,13 @@ type codec struct {
 	hashKey     []byte
 	hashFn      func() hash.Hash
 	block       cipher.Block
+	aead        cipher.AEAD
+	compressor  Compressor
 	maxLength   int
 	maxAge      int64
 	minAge      int64
 	serializer  Serializer
+	keyID       string
 	timestampFn func() int64
 	err         error
 }
@@ -33,6 +37,17 @@ type Codec interface {
 	Decode(name string, src []byte, dst any) error
 }
 
+// KeyIdentifier is implemented by codecs that write a key identifier into the
+// values they encode.
+//
+// Values that carry a key identifier are decoded only by the codec with the
+// matching KeyID, instead of trying every codec in turn.
+type KeyIdentifier interface {
+	KeyID() string
+}
+
+var _ KeyIdentifier = (*codec)(nil)
+
 // NewCodec returns a new Codec set up with the hash key, optionally configured
 // with additional provided CodecOption options.
 //
@@ -45,6 +60,10 @@ type Codec interface {
 // AES, used by default, valid lengths are 16, 24, or 32 bytes to select AES-128,
 // AES-192, or AES-256.
 //
+// An AEAD, set with WithAEAD or WithAESGCMKey, can be used instead of the
+// hashKey and blockKey. The AEAD will both encrypt and authenticate the cookie
+// value, and the hashKey may be left empty.
+//
 // Either options or setting sessions.Default* values can be used to configure
 // the codec.
 func NewCodec(hashKey []byte, options ...CodecOption) Codec {
@@ -57,29 +76,34 @@ func NewCodec(hashKey []byte, options ...CodecOption) Codec {
 		serializer: DefaultSerializer,
 	}
 
-	if len(hashKey) == 0 {
-		c.err = ErrHashKeyNotSet
-		return c
-	}
-
 	for _, option := range options {
 		option.configureCodec(c)
 	}
 
+	if len(hashKey) == 0 && c.aead == nil && c.err == nil {
+		c.err = ErrHashKeyNotSet
+	}
+
 	return c
 }
 
 // Encode encodes a session value using the codec.
 //
-// The value is serialized, optionally encrypted, encoded, and a MAC is created to
-// validate the value. The value is then encoded using base64.
+// The value is serialized, optionally compressed, optionally encrypted, encoded,
+// and a MAC is created to validate the value. The value is then encoded using
+// base64.
 //
 // Processing steps:
 //  1. Serialize; customize with WithSerializer
-//  2. Encrypt (optional); set with WithBlockKey or WithBlock
-//  3. Create MAC; customize with WithHashFn
-//  4. Encode using base64.URLEncoding
-//  5. Check length (optional); customize with WithMaxLength
+//  2. Compress (optional); set with WithCompressor
+//  3. Encrypt (optional); set with WithBlockKey or WithBlock
+//  4. Create MAC; customize with WithHashFn
+//  5. Encode using base64.URLEncoding; prefixed with the key ID if set with WithKeyID
+//  6. Check length (optional); customize with WithMaxLength
+//
+// When an AEAD has been set with WithAEAD or WithAESGCMKey, steps 3 and 4 are
+// replaced by sealing the value with the AEAD. The name and timestamp are bound
+// to the value as additional authenticated data.
 func (c *codec) Encode(name string, src any) ([]byte, error) {
 	if c.err != nil {
 		return nil, c.err
@@ -93,24 +117,45 @@ func (c *codec) Encode(name string, src any) ([]byte, error) {
 		return nil, errors.Join(ErrSerializeFailed, err)
 	}
 
-	// 2. Encrypt (optional)
-	if c.block != nil {
-		if data, err = c.encrypt(c.block, data); err != nil {
+	// 2. Compress (optional)
+	var compressed bool
+	if c.compressor != nil && !holdsSecret(src) {
+		if data, compressed, err = c.compress(data); err != nil {
 			return nil, err
 		}
 	}
 
-	data = c.encode(data)
+	if c.aead != nil {
+		// 3-4. Encrypt and authenticate
+		if data, err = c.seal(name, data, compressed); err != nil {
+			return nil, err
+		}
+	} else {
+		// 3. Encrypt (optional)
+		if c.block != nil {
+			if data, err = c.encrypt(c.block, data); err != nil {
+				return nil, err
+			}
+		}
 
-	// 3. Create MAC for "name|date|value with extra pipe to be used later
-	data = []byte(fmt.Sprintf("%s|%d|%s|", name, c.timestamp(), data))
-	mac := c.createMac(hmac.New(c.hashFn, c.hashKey), data[:len(data)-1])
-	data = append(data, mac...)[len(name)+1:]
+		data = c.encode(data)
+		if compressed {
+			data = append([]byte(compressedMarker), data...)
+		}
+
+		// 4. Create MAC for "name|date|value with extra pipe to be used later
+		data = []byte(fmt.Sprintf("%s|%d|%s|", name, c.timestamp(), data))
+		mac := c.createMac(hmac.New(c.hashFn, c.hashKey), data[:len(data)-1])
+		data = append(data, mac...)[len(name)+1:]
+	}
 
-	// 4. Encode
+	// 5. Encode
 	data = c.encode(data)
+	if c.keyID != "" {
+		data = append([]byte(c.keyID+keyIDSeparator), data...)
+	}
 
-	// 5. Check length
+	// 6. Check length
 	if c.maxLength != 0 && len(data) > c.maxLength {
 		return nil, ErrEncodedLengthTooLong
 	}
@@ -121,15 +166,20 @@ func (c *codec) Encode(name string, src any) ([]byte, error) {
 // Decode decodes a session value using the codec.
 //
 // The value is decoded using base64, the MAC is validated, decoded, and the
-// value is optionally decrypted. The value is then deserialized.
+// value is optionally decrypted and decompressed. The value is then
+// deserialized.
 //
 // Processing steps:
 //  1. Check length (optional); customize with WithMaxLength
-//  2. Decode using base64.URLEncoding
+//  2. Decode using base64.URLEncoding; after removing the key ID if one is present
 //  3. Verify the MAC; customize with WithHashFn
 //  4. Verify age; customize with WithMinAge and WithMaxAge
 //  5. Decrypt (optional); set with WithBlockKey or WithBlock
-//  6. Deserialize; customize with WithSerializer
+//  6. Decompress (if compressed); set with WithCompressor
+//  7. Deserialize; customize with WithSerializer
+//
+// When an AEAD has been set with WithAEAD or WithAESGCMKey, the value is opened
+// with the AEAD in place of steps 3 and 5.
 func (c *codec) Decode(name string, src []byte, dst any) error {
 	if c.err != nil {
 		return c.err
@@ -141,27 +191,33 @@ func (c *codec) Decode(name string, src []byte, dst any) error {
 	}
 
 	// 2. Decode
+	if keyID, value, ok := splitKeyID(src); ok {
+		if keyID != c.keyID {
+			return ErrKeyIDNotFound
+		}
+		src = value
+	}
 	data, err := c.decode(src)
 	if err != nil {
 		return err
 	}
 
 	// 3. Verify the MAC
-	parts := bytes.SplitN(data, []byte("|"), 3)
-	if len(parts) != 3 {
-		return ErrHMACIsInvalid
-	}
-	h := hmac.New(c.hashFn, c.hashKey)
-	data = append([]byte(name+"|"), data[:len(data)-len(parts[2])-1]...)
-	if err = c.verifyMac(h, data, parts[2]); err != nil {
-		return err
+	var t1 int64
+	var compressed bool
+	if c.aead != nil {
+		// the value is decrypted as it is verified
+		if t1, data, compressed, err = c.open(name, data); err != nil {
+			return err
+		}
+	} else {
+		if t1, data, err = c.verify(name, data); err != nil {
+			return err
+		}
+		data, compressed = bytes.CutPrefix(data, []byte(compressedMarker))
 	}
 
 	// 4. Verify age
-	var t1 int64
-	if t1, err = strconv.ParseInt(string(parts[0]), 10, 64); err != nil {
-		return ErrTimestampIsInvalid
-	}
 	t2 := c.timestamp()
 	if c.minAge != 0 && t1 > t2-c.minAge {
 		return ErrTimestampIsTooNew
@@ -170,19 +226,29 @@ func (c *codec) Decode(name string, src []byte, dst any) error {
 		return ErrTimestampIsExpired
 	}
 
-	data, err = c.decode(parts[1])
-	if err != nil {
-		return err
-	}
-
 	// 5. Decrypt (optional)
-	if c.block != nil {
-		if data, err = c.decrypt(c.block, data); err != nil {
+	if c.aead == nil {
+		if data, err = c.decode(data); err != nil {
 			return err
 		}
+		if c.block != nil {
+			if data, err = c.decrypt(c.block, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 6. Decompress (if compressed)
+	if compressed {
+		if c.compressor == nil {
+			return ErrDecompressFailed
+		}
+		if data, err = c.compressor.Decompress(data); err != nil {
+			return errors.Join(ErrDecompressFailed, err)
+		}
 	}
 
-	// 6. Deserialize
+	// 7. Deserialize
 	if err = c.serializer.Deserialize(data, dst); err != nil {
 		return errors.Join(ErrDeserializeFailed, err)
 	}
@@ -190,6 +256,101 @@ func (c *codec) Decode(name string, src []byte, dst any) error {
 	return nil
 }
 
+// KeyID returns the key identifier set with WithKeyID.
+func (c *codec) KeyID() string {
+	return c.keyID
+}
+
+// verify checks the MAC of a "date|value|mac" value and returns the timestamp
+// and the still encoded value.
+func (c *codec) verify(name string, data []byte) (int64, []byte, error) {
+	parts := bytes.SplitN(data, []byte("|"), 3)
+	if len(parts) != 3 {
+		return 0, nil, ErrHMACIsInvalid
+	}
+	h := hmac.New(c.hashFn, c.hashKey)
+	data = append([]byte(name+"|"), data[:len(data)-len(parts[2])-1]...)
+	if err := c.verifyMac(h, data, parts[2]); err != nil {
+		return 0, nil, err
+	}
+
+	timestamp, err := strconv.ParseInt(string(parts[0]), 10, 64)
+	if err != nil {
+		return 0, nil, ErrTimestampIsInvalid
+	}
+
+	return timestamp, parts[1], nil
+}
+
+// seal encrypts and authenticates the data with the AEAD.
+//
+// The sealed value is laid out as "header|nonce|ciphertext". The header is a
+// big-endian uint64 timestamp with the high byte reserved for flags. The name
+// and header are used as additional data.
+func (c *codec) seal(name string, data []byte, compressed bool) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Join(ErrGeneratingIV, err)
+	}
+
+	header := binary.BigEndian.AppendUint64(nil, uint64(c.timestamp())&aeadTimestampMask)
+	if compressed {
+		header[0] |= aeadFlagCompressed
+	}
+	sealed := append(header, nonce...)
+	return c.aead.Seal(sealed, nonce, data, c.additionalData(name, header)), nil
+}
+
+// open verifies and decrypts data that was sealed with the AEAD and returns
+// the timestamp, the plaintext, and whether the plaintext is compressed.
+func (c *codec) open(name string, data []byte) (int64, []byte, bool, error) {
+	headerSize := 8 + c.aead.NonceSize()
+	if len(data) < headerSize+c.aead.Overhead() {
+		return 0, nil, false, ErrHMACIsInvalid
+	}
+
+	header, nonce := data[:8], data[8:headerSize]
+	plaintext, err := c.aead.Open(nil, nonce, data[headerSize:], c.additionalData(name, header))
+	if err != nil {
+		return 0, nil, false, ErrHMACIsInvalid
+	}
+
+	compressed := header[0]&aeadFlagCompressed != 0
+	return int64(binary.BigEndian.Uint64(header) & aeadTimestampMask), plaintext, compressed, nil
+}
+
+func (c *codec) additionalData(name string, header []byte) []byte {
+	return append([]byte(name+"|"), header...)
+}
+
+// secretHolder is implemented by values that hold a secret next to data that
+// may be influenced by an attacker, such as the session values.
+//
+// These values are never compressed; the length of the compressed value would
+// reveal the secret to an attacker that can observe it, as in CRIME.
+type secretHolder interface {
+	holdsSecret() bool
+}
+
+func holdsSecret(src any) bool {
+	holder, ok := src.(secretHolder)
+	return ok && holder.holdsSecret()
+}
+
+// compress compresses the data and reports if the compressed data was used.
+//
+// The data is left uncompressed when compressing it does not make it smaller.
+func (c *codec) compress(data []byte) ([]byte, bool, error) {
+	compressed, err := c.compressor.Compress(data)
+	if err != nil {
+		return nil, false, errors.Join(ErrCompressFailed, err)
+	}
+	if len(compressed) >= len(data) {
+		return data, false, nil
+	}
+	return compressed, true, nil
+}
+
 func (c *codec) encrypt(block cipher.Block, data []byte) ([]byte, error) {
 	iv := make([]byte, block.BlockSize())
 	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
@@ -248,3 +409,40 @@ func (c *codec) decode(value []byte) ([]byte, error) {
 	}
 	return decoded[:b], nil
 }
+
+const keyIDSeparator = "."
+
+// compressedMarker is placed in front of compressed values before the MAC is
+// created. It is not part of the base64 URL alphabet.
+const compressedMarker = "~"
+
+const (
+	aeadFlagCompressed = 0x01
+	aeadTimestampMask  = 1<<56 - 1
+)
+
+// splitKeyID separates the key ID from an encoded value.
+//
+// Encoded values use the base64 URL alphabet which does not include the
+// separator, so values without a key ID are returned unchanged.
+func splitKeyID(value []byte) (string, []byte, bool) {
+	keyID, rest, found := bytes.Cut(value, []byte(keyIDSeparator))
+	if !found || !validKeyID(string(keyID)) {
+		return "", value, false
+	}
+	return string(keyID), rest, true
+}
+
+func validKeyID(keyID string) bool {
+	if keyID == "" {
+		return false
+	}
+	for _, r := range keyID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -17,7 +17,11 @@ import (
 // - WithHashFn: sets the hash function used by the codec
 // - WithBlockKey: sets the block key used by the codec; aes.NewCipher is used to create the block cipher
 // - WithBlock: sets the block cipher used by the codec
+// - WithAEAD: sets the AEAD used by the codec in place of the HMAC and block cipher
+// - WithAESGCMKey: sets the key used by the codec to create an AES-GCM AEAD
 // - WithSerializer: sets the serializer used by the codec
+// - WithCompressor: sets the compressor used by the codec
+// - WithKeyID: sets the key identifier written into values encoded by the codec
 type CodecOption interface {
 	configureCodec(*codec)
 }
@@ -109,6 +113,53 @@ func WithBlock(block cipher.Block) CodecOption {
 	return Block{block}
 }
 
+type AEAD struct {
+	cipher.AEAD
+}
+
+func (a AEAD) configureCodec(c *codec) {
+	c.aead = a.AEAD
+}
+
+// WithAEAD sets the AEAD used by the codec.
+//
+// The AEAD is used to both encrypt and authenticate the session cookie; the
+// hash key, hash function, and any block cipher are not used when it is set.
+// The cookie name and timestamp are authenticated as additional data.
+//
+// Any cipher.AEAD may be used. For example, to use ChaCha20-Poly1305:
+//
+//	aead, err := chacha20poly1305.New(key)
+//	if err != nil {
+//		return err
+//	}
+//	codec := sessions.NewCodec(nil, sessions.WithAEAD(aead))
+func WithAEAD(aead cipher.AEAD) CodecOption {
+	return AEAD{aead}
+}
+
+type AESGCMKey []byte
+
+func (k AESGCMKey) configureCodec(c *codec) {
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		c.err = errors.Join(ErrCreatingBlockCipher, err)
+		return
+	}
+	if c.aead, err = cipher.NewGCM(block); err != nil {
+		c.err = errors.Join(ErrCreatingBlockCipher, err)
+	}
+}
+
+// WithAESGCMKey sets the key used by the codec to create an AES-GCM AEAD.
+//
+// Valid key sizes are 16, 24, or 32 bytes to select AES-128, AES-192, or AES-256.
+//
+// See WithAEAD for how the AEAD is used by the codec.
+func WithAESGCMKey(key []byte) CodecOption {
+	return AESGCMKey(key)
+}
+
 type SerializerOption struct {
 	Serializer
 }
@@ -123,3 +174,47 @@ func (o SerializerOption) configureCodec(c *codec) {
 func WithSerializer(s Serializer) CodecOption {
 	return SerializerOption{s}
 }
+
+type CompressorOption struct {
+	Compressor
+}
+
+func (o CompressorOption) configureCodec(c *codec) {
+	c.compressor = o.Compressor
+}
+
+// WithCompressor sets the compressor used by the codec.
+//
+// The compressor is used to compress the serialized session values before they
+// are encrypted. Values are only compressed when it makes them smaller, and
+// values that were encoded without compression can still be decoded.
+//
+// Compressing a secret together with data an attacker can influence lets the
+// attacker recover the secret from the length of the value, as in CRIME. Do
+// not keep secrets in the values of sessions that are compressed; sessions
+// holding a CSRF secret are never compressed by the manager.
+func WithCompressor(c Compressor) CodecOption {
+	return CompressorOption{c}
+}
+
+type KeyID string
+
+func (k KeyID) configureCodec(c *codec) {
+	if !validKeyID(string(k)) {
+		c.err = ErrInvalidKeyID
+		return
+	}
+	c.keyID = string(k)
+}
+
+// WithKeyID sets the key identifier written into values encoded by the codec.
+//
+// When decoding, the key identifier is used to select the matching codec
+// directly instead of trying each codec in turn. Values without a key
+// identifier are still decoded by trying each codec.
+//
+// The key identifier may only contain the characters a-z, A-Z, 0-9, - and _.
+// It is not secret and should be short; for example "k1" or "2024-06".
+func WithKeyID(keyID string) CodecOption {
+	return KeyID(keyID)
+}
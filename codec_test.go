@@ -1,10 +1,12 @@
 package sessions
 
 import (
+	"crypto/aes"
 	"crypto/cipher"
 	"crypto/des"
 	"crypto/sha512"
 	"encoding/gob"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -194,6 +196,112 @@ func TestCodec(t *testing.T) {
 				Value: "session-value",
 			},
 		},
+		"with_aes_gcm_key": {
+			options: []CodecOption{
+				WithAESGCMKey(RandomBytes(32)),
+			},
+			name: "session-name",
+			src: sessionData{
+				Value: "session-value",
+			},
+		},
+		"with_aes_gcm_key_error": {
+			options: []CodecOption{
+				WithAESGCMKey(RandomBytes(1)),
+			},
+			name: "session-name",
+			src: sessionData{
+				Value: "session-value",
+			},
+			wantEncodeErr: ErrCreatingBlockCipher,
+		},
+		"with_aead": {
+			hashKey: []byte("hash-key"),
+			options: []CodecOption{
+				WithAEAD(func() cipher.AEAD {
+					b, _ := aes.NewCipher(RandomBytes(16))
+					aead, _ := cipher.NewGCM(b)
+					return aead
+				}()),
+			},
+			name: "session-name",
+			src: sessionData{
+				Value: "session-value",
+			},
+		},
+		"with_aead_max_age_error": {
+			options: []CodecOption{
+				WithAESGCMKey(RandomBytes(32)),
+				WithMaxAge(100),
+				withTimestampFn([]int64{0, 1000}), // simulate time passing 0 -> 1000
+			},
+			name: "session-name",
+			src: sessionData{
+				Value: "session-value",
+			},
+			wantDecodeErr: ErrTimestampIsExpired,
+		},
+		"with_aead_tampered": {
+			options: []CodecOption{
+				WithAESGCMKey(RandomBytes(32)),
+			},
+			name: "session-name",
+			src: sessionData{
+				Value: "session-value",
+			},
+			adjustCodec: func(c *codec) {
+				c.aead, _ = cipher.NewGCM(func() cipher.Block {
+					b, _ := aes.NewCipher(RandomBytes(32))
+					return b
+				}())
+			},
+			wantDecodeErr: ErrHMACIsInvalid,
+		},
+		"with_key_id": {
+			hashKey: []byte("hash-key"),
+			options: []CodecOption{
+				WithKeyID("k1"),
+			},
+			name: "session-name",
+			src: sessionData{
+				Value: "session-value",
+			},
+		},
+		"with_key_id_error": {
+			hashKey: []byte("hash-key"),
+			options: []CodecOption{
+				WithKeyID("k.1"),
+			},
+			name: "session-name",
+			src: sessionData{
+				Value: "session-value",
+			},
+			wantEncodeErr: ErrInvalidKeyID,
+		},
+		"with_key_id_mismatch": {
+			hashKey: []byte("hash-key"),
+			options: []CodecOption{
+				WithKeyID("k1"),
+			},
+			name: "session-name",
+			src: sessionData{
+				Value: "session-value",
+			},
+			adjustCodec: func(c *codec) {
+				c.keyID = "k2"
+			},
+			wantDecodeErr: ErrKeyIDNotFound,
+		},
+		"with_key_id_legacy_value": {
+			hashKey: []byte("hash-key"),
+			name:    "session-name",
+			src: sessionData{
+				Value: "session-value",
+			},
+			adjustCodec: func(c *codec) {
+				c.keyID = "k1"
+			},
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -222,3 +330,187 @@ func TestCodec(t *testing.T) {
 		})
 	}
 }
+
+func TestCodec_AEAD(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	aeadCodec := NewCodec(nil, WithAESGCMKey(RandomBytes(32)))
+	hmacCodec := NewCodec(RandomBytes(32), WithBlockKey(RandomBytes(32)))
+	src := sessionData{Value: "session-value"}
+
+	aeadEncoded, err := aeadCodec.Encode("session-name", src)
+	assert.NoError(t, err)
+	hmacEncoded, err := hmacCodec.Encode("session-name", src)
+	assert.NoError(t, err)
+
+	t.Run("name_is_authenticated", func(t *testing.T) {
+		var dst sessionData
+		err := aeadCodec.Decode("other-name", aeadEncoded, &dst)
+		assert.ErrorIs(t, err, ErrHMACIsInvalid)
+	})
+
+	t.Run("more_compact", func(t *testing.T) {
+		assert.Less(t, len(aeadEncoded), len(hmacEncoded))
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		proxy := &SessionProxy{
+			options: &CookieOptions{Name: "session-name"},
+			codecs:  []Codec{aeadCodec, hmacCodec},
+		}
+		for _, encoded := range [][]byte{aeadEncoded, hmacEncoded} {
+			var dst sessionData
+			assert.NoError(t, proxy.Decode(encoded, &dst))
+			assert.Equal(t, src, dst)
+		}
+	})
+}
+
+func TestCodec_Compression(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		options       []CodecOption
+		decodeOptions []CodecOption
+		src           sessionData
+		wantSmaller   bool
+		wantDecodeErr error
+	}
+
+	hashKey := RandomBytes(32)
+	aeadKey := RandomBytes(32)
+	large := sessionData{Value: strings.Repeat("session-value;", 100)}
+	small := sessionData{Value: "v"}
+
+	tests := map[string]testCase{
+		"gzip": {
+			options:       []CodecOption{WithCompressor(GzipCompressor{})},
+			decodeOptions: []CodecOption{WithCompressor(GzipCompressor{})},
+			src:           large,
+			wantSmaller:   true,
+		},
+		"flate": {
+			options:       []CodecOption{WithCompressor(FlateCompressor{})},
+			decodeOptions: []CodecOption{WithCompressor(FlateCompressor{})},
+			src:           large,
+			wantSmaller:   true,
+		},
+		"flate_with_block_key": {
+			options:       []CodecOption{WithCompressor(FlateCompressor{}), WithBlockKey(aeadKey)},
+			decodeOptions: []CodecOption{WithCompressor(FlateCompressor{}), WithBlockKey(aeadKey)},
+			src:           large,
+			wantSmaller:   true,
+		},
+		"flate_with_aead": {
+			options:       []CodecOption{WithCompressor(FlateCompressor{}), WithAESGCMKey(aeadKey)},
+			decodeOptions: []CodecOption{WithCompressor(FlateCompressor{}), WithAESGCMKey(aeadKey)},
+			src:           large,
+			wantSmaller:   true,
+		},
+		"not_smaller": {
+			options: []CodecOption{WithCompressor(GzipCompressor{})},
+			src:     small,
+		},
+		"uncompressed_value": {
+			decodeOptions: []CodecOption{WithCompressor(GzipCompressor{})},
+			src:           large,
+		},
+		"uncompressed_aead_value": {
+			options:       []CodecOption{WithAESGCMKey(aeadKey)},
+			decodeOptions: []CodecOption{WithCompressor(GzipCompressor{}), WithAESGCMKey(aeadKey)},
+			src:           large,
+		},
+		"no_compressor": {
+			options:       []CodecOption{WithCompressor(GzipCompressor{})},
+			src:           large,
+			wantSmaller:   true,
+			wantDecodeErr: ErrDecompressFailed,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			plainOptions := []CodecOption{WithMaxLength(0)}
+			for _, option := range tc.options {
+				if _, ok := option.(CompressorOption); !ok {
+					plainOptions = append(plainOptions, option)
+				}
+			}
+			encoder := NewCodec(hashKey, append([]CodecOption{WithMaxLength(0)}, tc.options...)...)
+			decoder := NewCodec(hashKey, append([]CodecOption{WithMaxLength(0)}, tc.decodeOptions...)...)
+			plain, err := NewCodec(hashKey, plainOptions...).Encode("session-name", tc.src)
+			assert.NoError(t, err)
+
+			// Act
+			encoded, err := encoder.Encode("session-name", tc.src)
+			assert.NoError(t, err)
+			var dst sessionData
+			err = decoder.Decode("session-name", encoded, &dst)
+
+			// Assert
+			assert.Equal(t, tc.wantSmaller, len(encoded) < len(plain))
+			if tc.wantDecodeErr != nil {
+				assert.ErrorIs(t, err, tc.wantDecodeErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.src, dst)
+		})
+	}
+}
+
+func TestCodec_CompressionWithSecret(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		metadata    *sessionMetadata
+		wantSmaller bool
+	}
+
+	tests := map[string]testCase{
+		"no_metadata": {
+			wantSmaller: true,
+		},
+		"metadata_without_secret": {
+			metadata:    &sessionMetadata{CreatedAt: 1, LastActiveAt: 1, TokenID: "token"},
+			wantSmaller: true,
+		},
+		"csrf_secret": {
+			metadata:    &sessionMetadata{CreatedAt: 1, LastActiveAt: 1, TokenID: "token", CSRFSecret: "secret"},
+			wantSmaller: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			hashKey := RandomBytes(32)
+			encoder := NewCodec(hashKey, WithMaxLength(0), WithCompressor(FlateCompressor{}))
+			plainEncoder := NewCodec(hashKey, WithMaxLength(0))
+			src := sessionEnvelope[sessionData]{
+				SessionValues:   sessionData{Value: strings.Repeat("session-value;", 100)},
+				SessionMetadata: tc.metadata,
+			}
+			plain, err := plainEncoder.Encode("session-name", src)
+			assert.NoError(t, err)
+
+			// Act
+			encoded, err := encoder.Encode("session-name", src)
+			assert.NoError(t, err)
+			var dst sessionEnvelope[*sessionData]
+			err = encoder.Decode("session-name", encoded, &dst)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantSmaller, len(encoded) < len(plain))
+			assert.Equal(t, src.SessionValues, *dst.SessionValues)
+		})
+	}
+}
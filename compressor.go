@@ -0,0 +1,76 @@
+package sessions
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+)
+
+// Compressor is an interface for compressing and decompressing session values.
+// They are used by the Codec to compress serialized session values.
+//
+// The following two implementations are provided:
+//   - GzipCompressor
+//   - FlateCompressor
+//
+// You can also implement your own compressor if you have specific requirements.
+// Use WithCompressor to set a compressor when creating a new codec.
+type Compressor interface {
+	Compress([]byte) ([]byte, error)
+	Decompress([]byte) ([]byte, error)
+}
+
+// GzipCompressor is a compressor that uses the compress/gzip package to compress and decompress session values.
+type GzipCompressor struct{}
+
+var _ Compressor = (*GzipCompressor)(nil)
+
+func (c GzipCompressor) Compress(src []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c GzipCompressor) Decompress(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// FlateCompressor is a compressor that uses the compress/flate package to compress and decompress session values.
+//
+// The output of flate is smaller than gzip as it does not include a header or checksum.
+type FlateCompressor struct{}
+
+var _ Compressor = (*FlateCompressor)(nil)
+
+func (c FlateCompressor) Compress(src []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := flate.NewWriter(buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c FlateCompressor) Decompress(src []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	return io.ReadAll(r)
+}
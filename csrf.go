@@ -0,0 +1,166 @@
+package sessions
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"net/http"
+)
+
+// CSRF protects against cross-site request forgery with tokens bound to a
+// secret kept in each session.
+//
+// The secret is kept with the session metadata, outside the session values.
+// Tokens are masked with a random one-time pad, so a different token is issued
+// for every request and the secret cannot be recovered by compression attacks
+// such as BREACH. Sessions holding a secret are never compressed by their
+// codecs. A new secret is created when the session is regenerated.
+//
+// New secrets are only kept once the session is saved; use CSRF with AutoSave,
+// or save the session before the response is written.
+type CSRF[T any] struct {
+	csrfConfig
+	manager SessionManager[T]
+}
+
+const csrfSecretLength = 32
+
+// NewCSRF returns a new CSRF that keeps the secrets in the sessions of the
+// manager, optionally configured with additional provided CSRFOption options.
+//
+// The manager must keep session metadata, such as by being created with
+// WithMetadata; ErrCSRFSecretNotKept is returned if it does not.
+func NewCSRF[T any](manager SessionManager[T], options ...CSRFOption) (*CSRF[T], error) {
+	if m, ok := manager.(interface{ keepsMetadata() bool }); !ok || !m.keepsMetadata() {
+		return nil, ErrCSRFSecretNotKept
+	}
+
+	c := &CSRF[T]{
+		csrfConfig: csrfConfig{
+			header: "X-CSRF-Token",
+			field:  "csrf_token",
+			failureHandler: func(w http.ResponseWriter, _ *http.Request, _ error) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			},
+		},
+		manager: manager,
+	}
+
+	for _, option := range options {
+		option.configureCSRF(&c.csrfConfig)
+	}
+
+	return c, nil
+}
+
+// Token returns a masked token for the session of the request.
+//
+// The token is accepted for any unsafe request made with the same session.
+func (c *CSRF[T]) Token(r *http.Request) (string, error) {
+	secret, err := c.secret(r)
+	if err != nil {
+		return "", err
+	}
+	return maskCSRFToken(secret)
+}
+
+// FormToken returns a masked token for the session of the request that is only
+// accepted for requests to the action path, such as the action of a form.
+func (c *CSRF[T]) FormToken(r *http.Request, action string) (string, error) {
+	secret, err := c.secret(r)
+	if err != nil {
+		return "", err
+	}
+	return maskCSRFToken(csrfActionSecret(secret, action))
+}
+
+// Middleware returns a handler that validates the token of every request with
+// an unsafe method before calling next.
+//
+// The token is read from the header, and then from the form field. Requests
+// with the methods GET, HEAD, OPTIONS, and TRACE are not validated.
+func (c *CSRF[T]) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// share the session holding the secret with the handler
+		r = withRegistry(r)
+
+		secret, err := c.secret(r)
+		if err != nil {
+			c.failureHandler(w, r, err)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(c.header)
+		if token == "" {
+			token = r.PostFormValue(c.field)
+		}
+		if !validCSRFToken(secret, r.URL.Path, token) {
+			c.failureHandler(w, r, ErrCSRFTokenInvalid)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// secret returns the secret of the session, creating it if it does not exist.
+func (c *CSRF[T]) secret(r *http.Request) ([]byte, error) {
+	session, err := c.manager.Get(r)
+	if err != nil {
+		return nil, err
+	}
+	if session.metadata == nil {
+		return nil, ErrCSRFSecretNotKept
+	}
+	if session.metadata.CSRFSecret == "" {
+		if err := session.metadata.rotateCSRFSecret(); err != nil {
+			return nil, err
+		}
+	}
+	return base64.RawURLEncoding.DecodeString(session.metadata.CSRFSecret)
+}
+
+// maskCSRFToken returns the token as a random one-time pad followed by the
+// token XORed with the pad.
+func maskCSRFToken(token []byte) (string, error) {
+	masked := make([]byte, 2*len(token))
+	if _, err := io.ReadFull(crand.Reader, masked[:len(token)]); err != nil {
+		return "", ErrGeneratingCSRFToken
+	}
+	subtle.XORBytes(masked[len(token):], masked[:len(token)], token)
+	return base64.RawURLEncoding.EncodeToString(masked), nil
+}
+
+func unmaskCSRFToken(token string) []byte {
+	masked, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(masked) != 2*csrfSecretLength {
+		return nil
+	}
+	unmasked := make([]byte, csrfSecretLength)
+	subtle.XORBytes(unmasked, masked[:csrfSecretLength], masked[csrfSecretLength:])
+	return unmasked
+}
+
+// csrfActionSecret derives the secret for the tokens of one action.
+func csrfActionSecret(secret []byte, action string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(action))
+	return mac.Sum(nil)
+}
+
+func validCSRFToken(secret []byte, action, token string) bool {
+	unmasked := unmaskCSRFToken(token)
+	if unmasked == nil {
+		return false
+	}
+	return hmac.Equal(unmasked, secret) || hmac.Equal(unmasked, csrfActionSecret(secret, action))
+}
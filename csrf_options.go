@@ -0,0 +1,62 @@
+package sessions
+
+import (
+	"net/http"
+)
+
+// CSRFOption is an option for configuring a CSRF.
+//
+// The following options are available:
+// - WithCSRFHeader: sets the header the token is read from
+// - WithCSRFField: sets the form field the token is read from
+// - WithCSRFFailureHandler: sets the handler for requests that fail validation
+type CSRFOption interface {
+	configureCSRF(*csrfConfig)
+}
+
+type csrfConfig struct {
+	header         string
+	field          string
+	failureHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+type CSRFHeader string
+
+func (h CSRFHeader) configureCSRF(c *csrfConfig) {
+	c.header = string(h)
+}
+
+// WithCSRFHeader sets the name of the request header the token is read from.
+//
+// The default is "X-CSRF-Token".
+func WithCSRFHeader(name string) CSRFOption {
+	return CSRFHeader(name)
+}
+
+type CSRFField string
+
+func (f CSRFField) configureCSRF(c *csrfConfig) {
+	c.field = string(f)
+}
+
+// WithCSRFField sets the name of the form field the token is read from when it
+// is not found in the request header.
+//
+// The default is "csrf_token".
+func WithCSRFField(name string) CSRFOption {
+	return CSRFField(name)
+}
+
+type CSRFFailureHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+func (h CSRFFailureHandler) configureCSRF(c *csrfConfig) {
+	c.failureHandler = h
+}
+
+// WithCSRFFailureHandler sets the handler that is called for requests that do
+// not pass validation, or for which the session could not be loaded.
+//
+// The default responds with 403 Forbidden.
+func WithCSRFFailureHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) CSRFOption {
+	return CSRFFailureHandler(handler)
+}
@@ -0,0 +1,164 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCSRF(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	// Arrange
+	manager := NewSessionManager[sessionData](
+		CookieOptions{Name: "session", MaxAge: 3600},
+		CookieStore{},
+		NewCodec(RandomBytes(32)),
+	)
+
+	// Act
+	csrf, err := NewCSRF(manager)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrCSRFSecretNotKept)
+	assert.Nil(t, csrf)
+}
+
+func TestCSRF_Middleware(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type tokens struct {
+		token     string
+		formToken string
+		cookies   []*http.Cookie
+	}
+
+	type testCase struct {
+		request    func(issued tokens, other tokens) *http.Request
+		wantStatus int
+	}
+
+	manager := NewSessionManagerWithOptions[sessionData](
+		CookieOptions{Name: "session", MaxAge: 3600},
+		CookieStore{},
+		[]Codec{NewCodec(RandomBytes(32))},
+		WithMetadata(),
+	)
+	csrf, err := NewCSRF(manager)
+	assert.NoError(t, err)
+	var issued tokens
+	handler := AutoSave(nil)(csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/regenerate" {
+			session, _ := manager.Get(r)
+			session.Regenerate()
+		}
+		if r.Method == http.MethodGet {
+			issued.token, _ = csrf.Token(r)
+			issued.formToken, _ = csrf.FormToken(r, "/form")
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+	issue := func(path string, cookies []*http.Cookie) tokens {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		issued.cookies = resp.Result().Cookies()
+		if len(issued.cookies) == 0 {
+			issued.cookies = cookies
+		}
+		return issued
+	}
+	post := func(path string, cookies []*http.Cookie, header string, form url.Values) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		return req
+	}
+
+	tests := map[string]testCase{
+		"header_token": {
+			request: func(issued tokens, _ tokens) *http.Request {
+				return post("/", issued.cookies, issued.token, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		"field_token": {
+			request: func(issued tokens, _ tokens) *http.Request {
+				return post("/", issued.cookies, "", url.Values{"csrf_token": {issued.token}})
+			},
+			wantStatus: http.StatusOK,
+		},
+		"form_token": {
+			request: func(issued tokens, _ tokens) *http.Request {
+				return post("/form", issued.cookies, "", url.Values{"csrf_token": {issued.formToken}})
+			},
+			wantStatus: http.StatusOK,
+		},
+		"form_token_other_action": {
+			request: func(issued tokens, _ tokens) *http.Request {
+				return post("/other", issued.cookies, "", url.Values{"csrf_token": {issued.formToken}})
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		"missing_token": {
+			request: func(issued tokens, _ tokens) *http.Request {
+				return post("/", issued.cookies, "", nil)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		"other_session_token": {
+			request: func(issued tokens, other tokens) *http.Request {
+				return post("/", issued.cookies, other.token, nil)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		"regenerated_session": {
+			request: func(issued tokens, _ tokens) *http.Request {
+				regenerated := issue("/regenerate", issued.cookies)
+				return post("/", regenerated.cookies, issued.token, nil)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		"regenerated_session_new_token": {
+			request: func(issued tokens, _ tokens) *http.Request {
+				regenerated := issue("/regenerate", issued.cookies)
+				return post("/", regenerated.cookies, regenerated.token, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			first := issue("/", nil)
+			again := issue("/", first.cookies)
+			assert.NotEqual(t, first.token, again.token)
+			other := issue("/", nil)
+			req := tc.request(first, other)
+			resp := httptest.NewRecorder()
+
+			// Act
+			handler.ServeHTTP(resp, req)
+
+			// Assert
+			assert.Equal(t, tc.wantStatus, resp.Code)
+		})
+	}
+}
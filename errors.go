@@ -5,18 +5,35 @@ import (
 )
 
 var (
-	ErrHashKeyNotSet        = errors.ErrInternalServerError.Msg("the hash key is not set for the codec")
-	ErrEncodedLengthTooLong = errors.ErrOutOfRange.Msg("the encoded value is too long")
-	ErrSerializeFailed      = errors.ErrInternalServerError.Msg("the value cannot be serialized")
-	ErrDeserializeFailed    = errors.ErrInternalServerError.Msg("the value cannot be deserialized")
-	ErrHMACIsInvalid        = errors.ErrBadRequest.Msg("the value cannot be validated")
-	ErrTimestampIsInvalid   = errors.ErrBadRequest.Msg("the timestamp is invalid")
-	ErrTimestampIsTooNew    = errors.ErrOutOfRange.Msg("the timestamp is too new")
-	ErrTimestampIsExpired   = errors.ErrOutOfRange.Msg("the timestamp has expired")
-	ErrCreatingBlockCipher  = errors.ErrInternalServerError.Msg("failed to create block cipher")
-	ErrGeneratingIV         = errors.ErrInternalServerError.Msg("error generating the random iv")
-	ErrDecryptionFailed     = errors.ErrInternalServerError.Msg("the value cannot be decrypted")
-	ErrNoCodecs             = errors.ErrInternalServerError.Msg("no codecs were provided")
-	ErrNoResponseWriter     = errors.ErrInternalServerError.Msg("no response writer was provided")
-	ErrInvalidSessionType   = errors.ErrBadRequest.Msg("the session type is incorrect")
+	ErrHashKeyNotSet             = errors.ErrInternalServerError.Msg("the hash key is not set for the codec")
+	ErrEncodedLengthTooLong      = errors.ErrOutOfRange.Msg("the encoded value is too long")
+	ErrSerializeFailed           = errors.ErrInternalServerError.Msg("the value cannot be serialized")
+	ErrDeserializeFailed         = errors.ErrInternalServerError.Msg("the value cannot be deserialized")
+	ErrCompressFailed            = errors.ErrInternalServerError.Msg("the value cannot be compressed")
+	ErrDecompressFailed          = errors.ErrInternalServerError.Msg("the value cannot be decompressed")
+	ErrHMACIsInvalid             = errors.ErrBadRequest.Msg("the value cannot be validated")
+	ErrTimestampIsInvalid        = errors.ErrBadRequest.Msg("the timestamp is invalid")
+	ErrTimestampIsTooNew         = errors.ErrOutOfRange.Msg("the timestamp is too new")
+	ErrTimestampIsExpired        = errors.ErrOutOfRange.Msg("the timestamp has expired")
+	ErrCreatingBlockCipher       = errors.ErrInternalServerError.Msg("failed to create block cipher")
+	ErrGeneratingIV              = errors.ErrInternalServerError.Msg("error generating the random iv")
+	ErrDecryptionFailed          = errors.ErrInternalServerError.Msg("the value cannot be decrypted")
+	ErrNoCodecs                  = errors.ErrInternalServerError.Msg("no codecs were provided")
+	ErrNoResponseWriter          = errors.ErrInternalServerError.Msg("no response writer was provided")
+	ErrInvalidSessionType        = errors.ErrBadRequest.Msg("the session type is incorrect")
+	ErrInvalidKeyID              = errors.ErrInternalServerError.Msg("the key id is invalid")
+	ErrKeyIDNotFound             = errors.ErrBadRequest.Msg("the key id was not recognized")
+	ErrRegenerateNotSupported    = errors.ErrNotImplemented.Msg("the store does not support regenerating session ids")
+	ErrSessionNotFound           = errors.ErrNotFound.Msg("the session was not found")
+	ErrInvalidSessionID          = errors.ErrBadRequest.Msg("the session id is invalid")
+	ErrGeneratingCSRFToken       = errors.ErrInternalServerError.Msg("error generating the csrf token")
+	ErrCSRFTokenInvalid          = errors.ErrForbidden.Msg("the csrf token is invalid")
+	ErrCSRFSecretNotKept         = errors.ErrInternalServerError.Msg("the session manager does not keep csrf secrets")
+	ErrGeneratingRememberMeToken = errors.ErrInternalServerError.Msg("error generating the remember-me token")
+	ErrRememberMeTokenNotFound   = errors.ErrNotFound.Msg("the remember-me token was not found")
+	ErrRememberMeTokenStolen     = errors.ErrUnauthorized.Msg("the remember-me token has already been used")
+	ErrRememberMeTokenChanged    = errors.ErrConflict.Msg("the remember-me token has been changed")
+	ErrListNotSupported          = errors.ErrNotImplemented.Msg("the store does not support listing the sessions of a subject")
+	ErrValuesNotSupported        = errors.ErrNotImplemented.Msg("the session manager does not support loading sessions from values")
+	ErrNoRegistry                = errors.ErrInternalServerError.Msg("the request does not carry a registry of sessions")
 )
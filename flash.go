@@ -16,6 +16,9 @@ type Flash struct {
 	flashes map[string]string
 	now     map[string]string
 	keep    map[string]string
+	// dirty is set when the stored messages have changed, including when
+	// messages were loaded into now and must be removed from storage
+	dirty bool
 }
 
 type flash struct {
@@ -42,9 +45,7 @@ func (f *Flash) Get(key string) string {
 
 	// delete the key from all possible locations
 	if message != "" {
-		delete(f.now, key)
-		delete(f.flashes, key)
-		delete(f.keep, key)
+		f.Remove(key)
 	}
 
 	return message
@@ -61,6 +62,7 @@ func (f *Flash) Add(key, message string) {
 		f.flashes = make(map[string]string)
 	}
 	f.flashes[key] = message
+	f.dirty = true
 }
 
 // Now adds a flash message for the given key
@@ -87,10 +89,17 @@ func (f *Flash) Keep(key, message string) {
 		f.keep = make(map[string]string)
 	}
 	f.keep[key] = message
+	f.dirty = true
 }
 
 // Remove removes a flash message for the given key
 func (f *Flash) Remove(key string) {
+	if _, exists := f.flashes[key]; exists {
+		f.dirty = true
+	}
+	if _, exists := f.keep[key]; exists {
+		f.dirty = true
+	}
 	delete(f.now, key)
 	delete(f.flashes, key)
 	delete(f.keep, key)
@@ -98,11 +107,25 @@ func (f *Flash) Remove(key string) {
 
 // Clear removes all flash messages
 func (f *Flash) Clear() {
+	if len(f.flashes) > 0 || len(f.keep) > 0 {
+		f.dirty = true
+	}
 	f.now = nil
 	f.flashes = nil
 	f.keep = nil
 }
 
+// Support for dirty tracking
+
+// changed reports if the messages that need to be stored have changed
+func (f *Flash) changed() bool {
+	return f.dirty
+}
+
+func (f *Flash) resetChanged() {
+	f.dirty = false
+}
+
 // Support for gob encoding
 
 // GobEncode encodes the flash messages for gob serialization
@@ -126,6 +149,7 @@ func (f *Flash) GobDecode(data []byte) error {
 	}
 	f.now = ff.Flashes
 	f.keep = ff.Keep
+	f.dirty = len(ff.Flashes) > 0
 	return nil
 }
 
@@ -149,6 +173,7 @@ func (f *Flash) UnmarshalJSON(data []byte) error {
 	}
 	f.now = ff.Flashes
 	f.keep = ff.Keep
+	f.dirty = len(ff.Flashes) > 0
 
 	return nil
 }
@@ -0,0 +1,225 @@
+// Package grpcsessions provides gRPC server interceptors that load and save
+// sessions with a sessions.SessionManager.
+//
+// The encoded session is read from the incoming metadata and the updated
+// session is returned in the header metadata, using the same codecs and store
+// as HTTP handlers.
+package grpcsessions
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/stackus/sessions"
+)
+
+// Option is an option for configuring the interceptors.
+//
+// The following options are available:
+// - WithMetadataKey: sets the metadata key the session is read from
+type Option interface {
+	configure(*config)
+}
+
+type config struct {
+	key string
+}
+
+type MetadataKey string
+
+func (k MetadataKey) configure(c *config) {
+	c.key = string(k)
+}
+
+// WithMetadataKey sets the metadata key the encoded session is read from and
+// written to.
+//
+// By default the session is read from the "cookie" metadata, as sent by
+// gRPC-Web and browser clients, and written to the "set-cookie" metadata. With
+// any other key, the encoded session is the whole value of the key, and an
+// empty value is written when the session has been deleted.
+func WithMetadataKey(key string) Option {
+	return MetadataKey(key)
+}
+
+type contextKey[T any] struct{}
+
+// FromContext returns the session that the interceptors placed in the context.
+func FromContext[T any](ctx context.Context) (*sessions.Session[T], bool) {
+	session, ok := ctx.Value(contextKey[T]{}).(*sessions.Session[T])
+	return session, ok
+}
+
+// UnaryServerInterceptor returns an interceptor that loads the session for
+// each call into the context, and saves it after the handler returns.
+//
+// The session is saved only if the handler succeeds.
+func UnaryServerInterceptor[T any](manager sessions.SessionManager[T], options ...Option) grpc.UnaryServerInterceptor {
+	cfg := newConfig(options)
+
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		session, err := load(ctx, manager, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := handler(context.WithValue(ctx, contextKey[T]{}, session), req)
+		if err != nil {
+			return nil, err
+		}
+
+		md, err := save(ctx, cfg, session)
+		if err != nil {
+			return nil, err
+		}
+		if md != nil {
+			if err := grpc.SetHeader(ctx, md); err != nil {
+				return nil, err
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that loads the session for
+// each stream into the context, and saves it just before the header metadata
+// is sent.
+//
+// The header is sent with the first message, when the handler sends the
+// header itself, or when the handler returns. Changes made to the session
+// after the header has been sent are not saved.
+func StreamServerInterceptor[T any](manager sessions.SessionManager[T], options ...Option) grpc.StreamServerInterceptor {
+	cfg := newConfig(options)
+
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		session, err := load(ss.Context(), manager, cfg)
+		if err != nil {
+			return err
+		}
+
+		stream := &sessionStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), contextKey[T]{}, session),
+			save: func() (metadata.MD, error) {
+				return save(ss.Context(), cfg, session)
+			},
+		}
+		if err := handler(srv, stream); err != nil {
+			return err
+		}
+		return stream.saveOnce()
+	}
+}
+
+type sessionStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	save  func() (metadata.MD, error)
+	saved bool
+}
+
+func (s *sessionStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *sessionStream) SendHeader(md metadata.MD) error {
+	if err := s.saveOnce(); err != nil {
+		return err
+	}
+	return s.ServerStream.SendHeader(md)
+}
+
+func (s *sessionStream) SendMsg(m any) error {
+	if err := s.saveOnce(); err != nil {
+		return err
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *sessionStream) saveOnce() error {
+	if s.saved {
+		return nil
+	}
+	s.saved = true
+
+	md, err := s.save()
+	if err != nil || md == nil {
+		return err
+	}
+	return s.ServerStream.SetHeader(md)
+}
+
+func newConfig(options []Option) config {
+	cfg := config{key: "cookie"}
+	for _, option := range options {
+		option.configure(&cfg)
+	}
+	return cfg
+}
+
+// load loads the session for the encoded value in the incoming metadata.
+//
+// Every cookie is passed to the manager so that sessions split across chunked
+// cookies by sessions.CookieStore can be loaded.
+func load[T any](ctx context.Context, manager sessions.SessionManager[T], cfg config) (*sessions.Session[T], error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var userAgent string
+	if values := md.Get("user-agent"); len(values) > 0 {
+		userAgent = values[0]
+	}
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	ctx = sessions.ContextWithClient(ctx, ip, userAgent)
+
+	values := md.Get(cfg.key)
+	if cfg.key == "cookie" {
+		r := &http.Request{Header: http.Header{"Cookie": values}}
+		return sessions.LoadFromValues(ctx, manager, func(name string) (string, bool) {
+			c, err := r.Cookie(name)
+			if err != nil {
+				return "", false
+			}
+			return c.Value, true
+		})
+	}
+
+	var value string
+	if len(values) > 0 {
+		value = values[0]
+	}
+	return sessions.LoadFromValue(ctx, manager, value)
+}
+
+// save saves the session and returns the header metadata to send, or nil if
+// the session did not need to be saved.
+func save[T any](ctx context.Context, cfg config, session *sessions.Session[T]) (metadata.MD, error) {
+	values, err := session.Encode(ctx)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	md := metadata.MD{}
+	for _, value := range values {
+		switch {
+		case cfg.key == "cookie":
+			md.Append("set-cookie", value.Cookie().String())
+		case value.MaxAge < 0:
+			md.Set(cfg.key, "")
+		default:
+			md.Set(cfg.key, value.Value)
+		}
+	}
+	return md, nil
+}
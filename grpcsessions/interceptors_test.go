@@ -0,0 +1,182 @@
+package grpcsessions
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/stackus/sessions"
+)
+
+type sessionData struct {
+	Count int64
+}
+
+func count(ctx context.Context) *wrapperspb.Int64Value {
+	session, ok := FromContext[sessionData](ctx)
+	if !ok {
+		return wrapperspb.Int64(-1)
+	}
+	session.Values.Count++
+	return wrapperspb.Int64(session.Values.Count)
+}
+
+var counterService = grpc.ServiceDesc{
+	ServiceName: "test.Counter",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Count",
+		Handler: func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := new(emptypb.Empty)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/test.Counter/Count"}
+			return interceptor(ctx, in, info, func(ctx context.Context, _ any) (any, error) {
+				return count(ctx), nil
+			})
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Watch",
+		ServerStreams: true,
+		Handler: func(_ any, stream grpc.ServerStream) error {
+			if err := stream.RecvMsg(new(emptypb.Empty)); err != nil {
+				return err
+			}
+			return stream.SendMsg(count(stream.Context()))
+		},
+	}},
+}
+
+func TestInterceptors(t *testing.T) {
+	type testCase struct {
+		store   sessions.Store
+		options []Option
+		issued  func(header metadata.MD) string
+		send    func(value string) metadata.MD
+	}
+
+	// issuedCookies returns the issued cookies as they are sent back
+	issuedCookies := func(header metadata.MD) string {
+		var pairs []string
+		for _, value := range header.Get("set-cookie") {
+			cookie, err := http.ParseSetCookie(value)
+			if err != nil {
+				return ""
+			}
+			pairs = append(pairs, cookie.Name+"="+cookie.Value)
+		}
+		return strings.Join(pairs, "; ")
+	}
+	sendCookies := func(value string) metadata.MD {
+		return metadata.Pairs("cookie", "other=1; "+value)
+	}
+
+	tests := map[string]testCase{
+		"cookie": {
+			store:  sessions.NewMemoryStore(0),
+			issued: issuedCookies,
+			send:   sendCookies,
+		},
+		"chunked_cookie": {
+			store:  sessions.NewCookieStore(sessions.WithChunkSize(20)),
+			issued: issuedCookies,
+			send:   sendCookies,
+		},
+		"chunked_metadata_key": {
+			store:   sessions.NewCookieStore(sessions.WithChunkSize(20)),
+			options: []Option{WithMetadataKey("x-session")},
+			issued: func(header metadata.MD) string {
+				if values := header.Get("x-session"); len(values) > 0 {
+					return values[0]
+				}
+				return ""
+			},
+			send: func(value string) metadata.MD {
+				return metadata.Pairs("x-session", value)
+			},
+		},
+		"metadata_key": {
+			store:   sessions.NewMemoryStore(0),
+			options: []Option{WithMetadataKey("x-session")},
+			issued: func(header metadata.MD) string {
+				if values := header.Get("x-session"); len(values) > 0 {
+					return values[0]
+				}
+				return ""
+			},
+			send: func(value string) metadata.MD {
+				return metadata.Pairs("x-session", value)
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			manager := sessions.NewSessionManager[sessionData](
+				sessions.CookieOptions{Name: "session", MaxAge: 3600},
+				tc.store,
+				sessions.NewCodec([]byte("0123456789abcdef0123456789abcdef")),
+			)
+			listener := bufconn.Listen(1024 * 1024)
+			server := grpc.NewServer(
+				grpc.UnaryInterceptor(UnaryServerInterceptor(manager, tc.options...)),
+				grpc.StreamInterceptor(StreamServerInterceptor(manager, tc.options...)),
+			)
+			server.RegisterService(&counterService, nil)
+			go func() { _ = server.Serve(listener) }()
+			t.Cleanup(server.Stop)
+
+			conn, err := grpc.Dial("bufnet",
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return listener.DialContext(ctx)
+				}),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+			)
+			assert.NoError(t, err)
+			t.Cleanup(func() { _ = conn.Close() })
+
+			// Act
+			var header metadata.MD
+			first := new(wrapperspb.Int64Value)
+			err = conn.Invoke(context.Background(), "/test.Counter/Count", new(emptypb.Empty), first, grpc.Header(&header))
+			assert.NoError(t, err)
+			value := tc.issued(header)
+
+			ctx := metadata.NewOutgoingContext(context.Background(), tc.send(value))
+			second := new(wrapperspb.Int64Value)
+			err = conn.Invoke(ctx, "/test.Counter/Count", new(emptypb.Empty), second, grpc.Header(&header))
+			assert.NoError(t, err)
+			value = tc.issued(header)
+
+			ctx = metadata.NewOutgoingContext(context.Background(), tc.send(value))
+			stream, err := conn.NewStream(ctx, &counterService.Streams[0], "/test.Counter/Watch")
+			assert.NoError(t, err)
+			assert.NoError(t, stream.SendMsg(new(emptypb.Empty)))
+			assert.NoError(t, stream.CloseSend())
+			third := new(wrapperspb.Int64Value)
+			assert.NoError(t, stream.RecvMsg(third))
+			header, err = stream.Header()
+			assert.NoError(t, err)
+
+			// Assert
+			assert.NotEmpty(t, value)
+			assert.Equal(t, int64(1), first.Value)
+			assert.Equal(t, int64(2), second.Value)
+			assert.Equal(t, int64(3), third.Value)
+			assert.NotEmpty(t, tc.issued(header))
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package sessions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// NewDerivedCodec returns a new Codec with keys derived from a master secret,
+// optionally configured with additional provided CodecOption options.
+//
+// The hash key and block key are derived from the secret using HKDF-SHA256
+// with the label, usually the name of the cookie, as the context. Codecs
+// created from the same secret with different labels will use unrelated keys,
+// so a single secret can be shared by every SessionManager.
+//
+// The derived block key selects AES-256. Options are applied after the
+// derived keys and may replace them.
+func NewDerivedCodec(secret []byte, label string, options ...CodecOption) Codec {
+	if len(secret) == 0 {
+		return NewCodec(nil, options...)
+	}
+
+	return NewCodec(
+		DeriveKey(secret, label+"|hash", 64),
+		append([]CodecOption{WithBlockKey(DeriveKey(secret, label+"|block", 32))}, options...)...,
+	)
+}
+
+// DeriveKey derives a key of the given length from the secret and purpose
+// using HKDF-SHA256 (RFC 5869).
+//
+// The purpose should be unique to each use of a key.
+//
+// Example:
+//
+//	codec := sessions.NewCodec(nil, sessions.WithAESGCMKey(sessions.DeriveKey(secret, "my-session|aead", 32)))
+func DeriveKey(secret []byte, purpose string, length int) []byte {
+	// extract; a nil salt is the same as a salt of HashLen zeros
+	extractor := hmac.New(sha256.New, make([]byte, sha256.Size))
+	extractor.Write(secret)
+	prk := extractor.Sum(nil)
+
+	// expand
+	var key, block []byte
+	expander := hmac.New(sha256.New, prk)
+	for counter := byte(1); len(key) < length; counter++ {
+		expander.Reset()
+		expander.Write(block)
+		expander.Write([]byte(purpose))
+		expander.Write([]byte{counter})
+		block = expander.Sum(nil)
+		key = append(key, block...)
+	}
+
+	return key[:length]
+}
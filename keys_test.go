@@ -0,0 +1,54 @@
+package sessions
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 5869 test case 3; SHA-256 with zero-length salt and info
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	want := "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8"
+
+	got := DeriveKey(ikm, "", 42)
+
+	assert.Equal(t, want, hex.EncodeToString(got))
+}
+
+func TestNewDerivedCodec(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	secret := RandomBytes(32)
+	src := sessionData{Value: "session-value"}
+
+	encoded, err := NewDerivedCodec(secret, "session").Encode("session", src)
+	assert.NoError(t, err)
+
+	t.Run("same_label", func(t *testing.T) {
+		var dst sessionData
+		err := NewDerivedCodec(secret, "session").Decode("session", encoded, &dst)
+		assert.NoError(t, err)
+		assert.Equal(t, src, dst)
+	})
+
+	t.Run("different_label", func(t *testing.T) {
+		var dst sessionData
+		err := NewDerivedCodec(secret, "other").Decode("session", encoded, &dst)
+		assert.ErrorIs(t, err, ErrHMACIsInvalid)
+	})
+
+	t.Run("different_secret", func(t *testing.T) {
+		var dst sessionData
+		err := NewDerivedCodec(RandomBytes(32), "session").Decode("session", encoded, &dst)
+		assert.ErrorIs(t, err, ErrHMACIsInvalid)
+	})
+
+	t.Run("no_secret", func(t *testing.T) {
+		_, err := NewDerivedCodec(nil, "session").Encode("session", src)
+		assert.ErrorIs(t, err, ErrHashKeyNotSet)
+	})
+}
@@ -1,7 +1,11 @@
 package sessions
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"net/http"
+	"time"
 )
 
 type SessionManager[T any] interface {
@@ -9,7 +13,15 @@ type SessionManager[T any] interface {
 	Save(w http.ResponseWriter, r *http.Request, session *Session[T]) error
 }
 
+// ActiveSession is a session of a subject as it is listed by ListSessions.
+type ActiveSession[T any] struct {
+	ID       string
+	Values   T
+	Metadata Metadata
+}
+
 type sessionManager[T any] struct {
+	managerConfig
 	options CookieOptions
 	store   Store
 	codecs  []Codec
@@ -23,70 +35,533 @@ func NewSessionManager[T any](options CookieOptions, store Store, codecs ...Code
 	}
 }
 
+// NewSessionManagerWithOptions returns a new SessionManager like
+// NewSessionManager, additionally configured with the provided
+// SessionManagerOption options.
+func NewSessionManagerWithOptions[T any](options CookieOptions, store Store, codecs []Codec, managerOptions ...SessionManagerOption) SessionManager[T] {
+	sm := &sessionManager[T]{
+		options: options,
+		store:   store,
+		codecs:  codecs,
+	}
+
+	for _, option := range managerOptions {
+		option.configureSessionManager(&sm.managerConfig)
+	}
+
+	return sm
+}
+
 // Get returns a session for the given request and cookie name.
 //
-// The returned session will inherit the options set in the manager.
+// The returned session will inherit the options set in the manager. The session
+// is kept in the registry of the request, when its context carries one, and is
+// returned again by later calls with the request.
 func (sm *sessionManager[T]) Get(r *http.Request) (*Session[T], error) {
 	reg := getRegistry(r)
-	if session := reg.get(sm.options.Name); session != nil {
-		if s, ok := session.(*Session[T]); ok {
-			return s, nil
+	if reg != nil {
+		if session := reg.get(sm.options.Name); session != nil {
+			if s, ok := session.(*Session[T]); ok {
+				return s, nil
+			}
+			return nil, ErrInvalidSessionType
 		}
-		return nil, ErrInvalidSessionType
+	}
+
+	clientIP := sm.clientIP
+	if clientIP == nil {
+		clientIP = RemoteAddrIP
+	}
+	ctx := ContextWithClient(r.Context(), clientIP(r), r.UserAgent())
+
+	session, err := sm.loadFromValues(ctx, requestReader(sm.transport, r), !namedValues(sm.transport))
+	if err != nil {
+		return nil, err
+	}
+
+	if reg != nil {
+		reg.set(sm.options.Name, session)
+	}
+
+	return session, nil
+}
+
+// LoadFromValue returns the session of the manager for the encoded value the
+// client sent, or a new session if the value is empty.
+//
+// This is for callers without an http.Request, such as job workers and gRPC
+// services. The value is not split across chunked cookies when the session is
+// saved with Session.Encode. Use ContextWithClient to record the client in the
+// metadata of new sessions.
+//
+// ErrValuesNotSupported is returned if the manager was not created by this
+// package.
+func LoadFromValue[T any](ctx context.Context, manager SessionManager[T], encoded string) (*Session[T], error) {
+	sm, ok := manager.(*sessionManager[T])
+	if !ok {
+		return nil, ErrValuesNotSupported
+	}
+	return sm.loadFromValues(ctx, func(name string) (string, bool) {
+		return encoded, name == sm.options.Name && encoded != ""
+	}, true)
+}
+
+// LoadFromValues returns the session of the manager for the encoded values the
+// client sent, such as its cookies, or a new session if there are none.
+//
+// The values function returns the value the client sent for each name, so
+// that values split across chunked cookies by CookieStore can be read, and the
+// chunks that are no longer needed can be removed by Session.Encode. Otherwise
+// it is the same as LoadFromValue.
+func LoadFromValues[T any](ctx context.Context, manager SessionManager[T], values func(name string) (string, bool)) (*Session[T], error) {
+	sm, ok := manager.(*sessionManager[T])
+	if !ok {
+		return nil, ErrValuesNotSupported
+	}
+	return sm.loadFromValues(ctx, values, false)
+}
+
+// loadFromValues loads the session with the values the client sent; Get is a
+// thin layer over it.
+func (sm *sessionManager[T]) loadFromValues(ctx context.Context, values func(name string) (string, bool), singleValue bool) (*Session[T], error) {
+	proxy := sm.newProxy()
+	proxy.incoming = values
+	proxy.singleValue = singleValue
+	value, found := proxy.requestCookie(sm.options.Name)
+	session, err := sm.load(ctx, proxy, value, found)
+	if err != nil {
+		return nil, err
+	}
+
+	session.incoming = values
+	session.singleValue = singleValue
+
+	return session, nil
+}
+
+// newProxy returns a proxy for loading a session.
+func (sm *sessionManager[T]) newProxy() *SessionProxy {
+	values := new(T)
+	if initable, ok := any(values).(interface{ Init() }); ok {
+		initable.Init()
 	}
 
 	proxy := &SessionProxy{
-		Values:  new(T),
-		req:     r,
+		Values:  values,
 		options: &sm.options,
 		codecs:  sm.codecs,
 	}
-
-	if initable, ok := proxy.Values.(interface{ Init() }); ok {
-		initable.Init()
+	if sm.keepsMetadata() {
+		proxy.Values = &sessionEnvelope[*T]{SessionValues: values}
 	}
+	return proxy
+}
 
+func (sm *sessionManager[T]) load(ctx context.Context, proxy *SessionProxy, encoded string, found bool) (*Session[T], error) {
 	var err error
-	if c, cErr := r.Cookie(sm.options.Name); cErr == nil {
-		err = sm.store.Get(r.Context(), proxy, c.Value)
+	if found {
+		err = sm.store.Get(ctx, proxy, encoded)
 	} else {
 		// start with IsNew = true; if the store needs or wants to set it to false, it may
 		proxy.IsNew = true
-		err = sm.store.New(r.Context(), proxy)
+		err = sm.store.New(ctx, proxy)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	values, ok := proxy.Values.(*T)
-	if !ok {
-		return nil, ErrInvalidSessionType
+	var values *T
+	var metadata *sessionMetadata
+	if sm.keepsMetadata() {
+		env, ok := proxy.Values.(*sessionEnvelope[*T])
+		if !ok {
+			return nil, ErrInvalidSessionType
+		}
+		values, metadata = env.SessionValues, env.SessionMetadata
+	} else {
+		var ok bool
+		if values, ok = proxy.Values.(*T); !ok {
+			return nil, ErrInvalidSessionType
+		}
 	}
 
 	session := &Session[T]{
 		Values:   *values,
 		IsNew:    proxy.IsNew,
 		storeKey: proxy.ID,
+		keyID:    proxy.KeyID(),
+		reissue:  proxy.NeedsReissue(),
+		metadata: metadata,
 		manager:  sm,
 		options:  *proxy.options,
 	}
 
-	reg.set(sm.options.Name, session)
+	if sm.keepsMetadata() {
+		if session.metadata == nil {
+			session.metadata = sm.newMetadata(ctx)
+		}
+		if session.metadata.TokenID == "" {
+			session.metadata.TokenID = randomID(16)
+		}
+		session.subject = session.metadata.Subject
+		if !session.IsNew {
+			if session, err = sm.checkSession(ctx, session); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// sessions saved without metadata are saved again to add it
+	if sm.tracksChanges() && !session.IsNew && (metadata != nil || !sm.keepsMetadata()) {
+		session.snapshot = sm.snapshot(session)
+	}
 
 	return session, nil
 }
 
 func (sm *sessionManager[T]) Save(w http.ResponseWriter, r *http.Request, session *Session[T]) error {
-	proxy := &SessionProxy{
-		req:     r,
-		resp:    w,
-		options: &session.options,
-		codecs:  sm.codecs,
-		Values:  session.Values,
-		ID:      session.storeKey,
-		IsNew:   session.IsNew,
+	return sm.save(r.Context(), session, &SessionProxy{
+		incoming:    requestReader(sm.transport, r),
+		outgoing:    responseWriter(sm.transport, w),
+		singleValue: !namedValues(sm.transport),
+	})
+}
+
+// encode saves the session with the values it was loaded from and returns the
+// values that are to be sent to the client.
+func (sm *sessionManager[T]) encode(ctx context.Context, session *Session[T]) ([]EncodedValue, error) {
+	var values []EncodedValue
+	err := sm.save(ctx, session, &SessionProxy{
+		incoming: session.incoming,
+		outgoing: func(value EncodedValue) error {
+			values = append(values, value)
+			return nil
+		},
+		singleValue: session.singleValue,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// save saves the session with the proxy, which has been prepared with where
+// the cookies are to be sent.
+func (sm *sessionManager[T]) save(ctx context.Context, session *Session[T], proxy *SessionProxy) error {
+	if sm.tracksChanges() && !sm.changed(session) {
+		return nil
+	}
+
+	proxy.options = &session.options
+	proxy.codecs = sm.codecs
+	proxy.Values = session.Values
+	proxy.ID = session.storeKey
+	proxy.IsNew = session.IsNew
+	proxy.subject = session.subject
+	if sm.keepsMetadata() {
+		metadata := *session.metadata
+		// slide the idle window
+		metadata.LastActiveAt = sm.now().Unix()
+		if session.regenerate || metadata.Subject != session.subject {
+			// the session is issued again, such as when the user logs in, and
+			// is no longer revoked by earlier revocations of the subject
+			metadata.IssuedAt = sm.now().UnixNano()
+		}
+		metadata.Subject = session.subject
+		metadata.KeyID = ""
+		if len(sm.codecs) > 0 {
+			if identifier, ok := sm.codecs[0].(KeyIdentifier); ok {
+				metadata.KeyID = identifier.KeyID()
+			}
+		}
+		if session.regenerate {
+			// a regenerated session may not be revoked by the previous token ID
+			metadata.TokenID = randomID(16)
+		}
+		proxy.Values = sessionEnvelope[T]{SessionValues: session.Values, SessionMetadata: &metadata}
+	}
+
+	if session.regenerate {
+		regenerator, ok := sm.store.(Regenerator)
+		if !ok {
+			return ErrRegenerateNotSupported
+		}
+		if err := regenerator.Regenerate(ctx, proxy); err != nil {
+			return err
+		}
+		session.regenerate = false
+	} else if err := sm.store.Save(ctx, proxy); err != nil {
+		return err
 	}
 
-	return sm.store.Save(r.Context(), proxy)
+	// the store may have assigned a new ID to the session
+	session.storeKey = proxy.ID
+	if env, ok := proxy.Values.(sessionEnvelope[T]); ok {
+		session.metadata = env.SessionMetadata
+	}
+
+	if sm.tracksChanges() {
+		if tracker, ok := any(&session.Values).(changeTracker); ok {
+			tracker.resetChanged()
+		}
+		session.snapshot = sm.snapshot(session)
+	}
+
+	return nil
+}
+
+// ListSessions returns a page of the sessions of the manager that belong to
+// the subject with their values and metadata, for pages such as a list of
+// active devices.
+//
+// The after and limit arguments and the returned cursor page through the
+// sessions as described by SubjectLister. Sessions that cannot be decoded by
+// the codecs of the manager are skipped. ErrListNotSupported is returned if
+// the store of the manager does not implement SubjectLister, or the manager
+// was not created by this package.
+func ListSessions[T any](ctx context.Context, manager SessionManager[T], subject, after string, limit int) ([]ActiveSession[T], string, error) {
+	sm, ok := manager.(*sessionManager[T])
+	if !ok {
+		return nil, "", ErrListNotSupported
+	}
+	lister, ok := sm.store.(SubjectLister)
+	if !ok {
+		return nil, "", ErrListNotSupported
+	}
+
+	stored, next, err := lister.ListSubject(ctx, subject, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessions := make([]ActiveSession[T], 0, len(stored))
+	for _, s := range stored {
+		values := new(T)
+		if initable, ok := any(values).(interface{ Init() }); ok {
+			initable.Init()
+		}
+		proxy := &SessionProxy{
+			ID:      s.ID,
+			options: &sm.options,
+			codecs:  sm.codecs,
+		}
+		env := &sessionEnvelope[*T]{SessionValues: values}
+		if err := proxy.Decode(s.Data, env); err != nil {
+			continue
+		}
+		session := ActiveSession[T]{
+			ID:     s.ID,
+			Values: *env.SessionValues,
+		}
+		if env.SessionMetadata != nil {
+			session.Metadata = env.SessionMetadata.metadata()
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, next, nil
+}
+
+// DeleteSession deletes a session of the manager that belongs to the subject,
+// such as to sign out a device.
+//
+// ErrSessionNotFound is returned if the session does not belong to the
+// subject, and ErrListNotSupported as it is by ListSessions.
+func DeleteSession[T any](ctx context.Context, manager SessionManager[T], subject, id string) error {
+	sm, ok := manager.(*sessionManager[T])
+	if !ok {
+		return ErrListNotSupported
+	}
+	lister, ok := sm.store.(SubjectLister)
+	if !ok {
+		return ErrListNotSupported
+	}
+	return lister.DeleteSubjectSession(ctx, subject, id)
+}
+
+// snapshot records the state of the session that is compared by changed.
+func (sm *sessionManager[T]) snapshot(session *Session[T]) *sessionSnapshot {
+	return &sessionSnapshot{
+		fingerprint: fingerprint(session.Values),
+		options:     session.options,
+		subject:     session.subject,
+		csrfSecret:  session.csrfSecret(),
+	}
+}
+
+// changed reports if the session needs to be saved to the store.
+func (sm *sessionManager[T]) changed(session *Session[T]) bool {
+	if session.snapshot == nil || session.reissue || session.regenerate || session.options != session.snapshot.options ||
+		session.subject != session.snapshot.subject || session.csrfSecret() != session.snapshot.csrfSecret {
+		return true
+	}
+	if sm.refreshDue(session) {
+		return true
+	}
+	if tracker, ok := any(&session.Values).(changeTracker); ok && tracker.changed() {
+		return true
+	}
+	current := fingerprint(session.Values)
+	return current == nil || !bytes.Equal(current, session.snapshot.fingerprint)
+}
+
+// refreshDue reports if an unchanged session should be saved again to extend
+// its lifetime.
+func (sm *sessionManager[T]) refreshDue(session *Session[T]) bool {
+	if session.metadata == nil {
+		return false
+	}
+	elapsed := sm.now().Sub(session.metadata.lastActiveAt())
+	if sm.idleTimeout > 0 && elapsed >= sm.idleTimeout/idleRefreshDivisor {
+		return true
+	}
+	if sm.slidingRefresh > 0 && session.options.MaxAge > 0 {
+		lifetime := time.Duration(session.options.MaxAge) * time.Second
+		if elapsed >= time.Duration(float64(lifetime)*sm.slidingRefresh) {
+			return true
+		}
+	}
+	return false
+}
+
+// tracksChanges reports if the manager skips saving unchanged sessions.
+func (sm *sessionManager[T]) tracksChanges() bool {
+	return sm.dirtyTracking || sm.slidingRefresh > 0
+}
+
+// keepsMetadata reports if the manager needs to keep metadata with the values.
+func (sm *sessionManager[T]) keepsMetadata() bool {
+	return sm.metadata || sm.idleTimeout > 0 || sm.absoluteTimeout > 0 || sm.slidingRefresh > 0 || sm.revocationChecker != nil
+}
+
+// newMetadata returns the metadata for a new session. The client is only
+// recorded when metadata has been asked for with WithMetadata.
+func (sm *sessionManager[T]) newMetadata(ctx context.Context) *sessionMetadata {
+	metadata := newSessionMetadata(ctx, sm.now())
+	if !sm.metadata {
+		metadata.IP, metadata.UserAgent = "", ""
+	}
+	return metadata
+}
+
+// timedOut reports if the session has exceeded the idle or absolute timeout.
+func (sm *sessionManager[T]) timedOut(metadata *sessionMetadata) bool {
+	now := sm.now()
+	if sm.idleTimeout > 0 && now.Sub(metadata.lastActiveAt()) > sm.idleTimeout {
+		return true
+	}
+	if sm.absoluteTimeout > 0 && now.Sub(metadata.createdAt()) > sm.absoluteTimeout {
+		return true
+	}
+	return false
+}
+
+// checkSession returns a fresh session in place of a session which has timed
+// out or has been revoked.
+func (sm *sessionManager[T]) checkSession(ctx context.Context, session *Session[T]) (*Session[T], error) {
+	if sm.timedOut(session.metadata) {
+		fresh, err := sm.replaceSession(ctx, session)
+		if err != nil {
+			return nil, err
+		}
+		fresh.timedOut = true
+		return fresh, nil
+	}
+
+	if sm.revocationChecker == nil {
+		return session, nil
+	}
+	revoked, err := sm.revocationChecker.IsRevoked(ctx, session.metadata.TokenID, session.subject, session.metadata.issuedAt())
+	if err != nil || !revoked {
+		return session, err
+	}
+	fresh, err := sm.replaceSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	fresh.revoked = true
+	return fresh, nil
+}
+
+// replaceSession returns a fresh session to replace a session that may no
+// longer be used.
+//
+// The fresh session is moved to a new ID when it is saved if the store
+// supports it, otherwise the previous session is deleted if the store supports
+// it and the store will assign a new ID.
+func (sm *sessionManager[T]) replaceSession(ctx context.Context, session *Session[T]) (*Session[T], error) {
+	values := new(T)
+	if initable, ok := any(values).(interface{ Init() }); ok {
+		initable.Init()
+	}
+
+	fresh := &Session[T]{
+		Values:   *values,
+		IsNew:    true,
+		metadata: sm.newMetadata(ctx),
+		manager:  sm,
+		options:  session.options,
+	}
+	if session.storeKey == "" {
+		return fresh, nil
+	}
+	if _, ok := sm.store.(Regenerator); ok {
+		fresh.storeKey = session.storeKey
+		fresh.regenerate = true
+	} else if deleter, ok := sm.store.(Deleter); ok {
+		if err := deleter.Delete(ctx, session.storeKey); err != nil {
+			return nil, err
+		}
+	}
+	return fresh, nil
+}
+
+// revokeSubject deletes the sessions of the subject if the store implements
+// SubjectIndexer, and revokes them if the RevocationChecker implements
+// SubjectRevoker.
+func (sm *sessionManager[T]) revokeSubject(ctx context.Context, subject string) error {
+	if indexer, ok := sm.store.(SubjectIndexer); ok {
+		if err := indexer.RevokeAll(ctx, subject); err != nil {
+			return err
+		}
+	}
+	if revoker, ok := sm.revocationChecker.(SubjectRevoker); ok {
+		return revoker.RevokeSubject(ctx, subject, sm.now())
+	}
+	return nil
+}
+
+func (sm *sessionManager[T]) now() time.Time {
+	if sm.nowFn != nil {
+		return sm.nowFn()
+	}
+	return time.Now()
+}
+
+// changeTracker is implemented by values which change in ways that are not
+// visible in their serialized form, such as Flash.
+type changeTracker interface {
+	changed() bool
+	resetChanged()
+}
+
+type sessionSnapshot struct {
+	fingerprint []byte
+	options     CookieOptions
+	subject     string
+	csrfSecret  string
+}
+
+// fingerprint returns a hash of the serialized values, or nil if the values
+// cannot be serialized.
+//
+// The values are serialized the same way they are passed to the codecs.
+func fingerprint(values any) []byte {
+	data, err := DefaultSerializer.Serialize(values)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
 }
@@ -0,0 +1,195 @@
+package sessions
+
+import (
+	"net/http"
+	"time"
+)
+
+// SessionManagerOption is an option for configuring a SessionManager.
+//
+// The following options are available:
+// - WithDirtyTracking: skips saving sessions that have not changed
+// - WithIdleTimeout: expires sessions that have not been saved recently
+// - WithAbsoluteTimeout: expires sessions some time after they were created
+// - WithSlidingExpiration: extends the lifetime of sessions that are in use
+// - WithRevocationChecker: replaces sessions that have been revoked
+// - WithMetadata: keeps metadata about each session
+// - WithClientIP: sets how the client IP address is found for the metadata
+// - WithTransport: sets how the session value is carried to and from clients
+type SessionManagerOption interface {
+	configureSessionManager(*managerConfig)
+}
+
+type managerConfig struct {
+	dirtyTracking     bool
+	idleTimeout       time.Duration
+	absoluteTimeout   time.Duration
+	slidingRefresh    float64
+	revocationChecker RevocationChecker
+	metadata          bool
+	clientIP          func(r *http.Request) string
+	transport         Transport
+	nowFn             func() time.Time
+}
+
+// idleRefreshDivisor sets how often, as a fraction of the idle timeout, an
+// unchanged session is saved to slide the idle window when dirty tracking is
+// enabled.
+const idleRefreshDivisor = 10
+
+type DirtyTracking bool
+
+func (d DirtyTracking) configureSessionManager(c *managerConfig) {
+	c.dirtyTracking = bool(d)
+}
+
+// WithDirtyTracking enables skipping the saving of sessions that have not
+// changed since they were loaded.
+//
+// A fingerprint of the session values is taken when a session is loaded, and
+// both Session.Save and the registry-wide Save will not write to the store or
+// set the session cookie when the fingerprint and cookie options are
+// unchanged. New sessions, and sessions that need to be reissued, are always
+// saved. The fingerprint is created with the DefaultSerializer.
+//
+// Without dirty tracking every save refreshes the expiration of the session
+// cookie, which will no longer happen for unchanged sessions.
+func WithDirtyTracking() SessionManagerOption {
+	return DirtyTracking(true)
+}
+
+type IdleTimeout time.Duration
+
+func (t IdleTimeout) configureSessionManager(c *managerConfig) {
+	c.idleTimeout = time.Duration(t)
+}
+
+// WithIdleTimeout sets how long a session may go without being saved before it
+// expires.
+//
+// The time of the last activity is kept with the session and is updated every
+// time the session is saved. When dirty tracking is enabled, unchanged
+// sessions are still saved every tenth of the timeout to keep them active.
+//
+// A session that has been idle for longer than the timeout is replaced by a
+// new session when it is loaded; Session.TimedOut will return true.
+func WithIdleTimeout(timeout time.Duration) SessionManagerOption {
+	return IdleTimeout(timeout)
+}
+
+type AbsoluteTimeout time.Duration
+
+func (t AbsoluteTimeout) configureSessionManager(c *managerConfig) {
+	c.absoluteTimeout = time.Duration(t)
+}
+
+// WithAbsoluteTimeout sets how long a session may exist, regardless of activity,
+// before it expires.
+//
+// The time the session was created is kept with the session. A session older
+// than the timeout is replaced by a new session when it is loaded;
+// Session.TimedOut will return true.
+func WithAbsoluteTimeout(timeout time.Duration) SessionManagerOption {
+	return AbsoluteTimeout(timeout)
+}
+
+type SlidingExpiration float64
+
+func (f SlidingExpiration) configureSessionManager(c *managerConfig) {
+	if f <= 0 || f > 1 {
+		c.slidingRefresh = 0
+		return
+	}
+	c.slidingRefresh = float64(f)
+}
+
+// WithSlidingExpiration extends the lifetime of sessions that are in use.
+//
+// Sessions are only saved when they have changed, as with WithDirtyTracking.
+// An unchanged session is saved again, reissuing the session cookie and
+// extending the expiration of the session in the store, once the refresh
+// fraction of its MaxAge has passed since it was last saved. For example, with
+// a refresh fraction of 0.5 and a MaxAge of one day, a session in use is
+// extended at most twice a day.
+//
+// A refresh fraction outside the range (0, 1] disables sliding expiration.
+// Sessions with a MaxAge of zero or less are not extended.
+func WithSlidingExpiration(refreshFraction float64) SessionManagerOption {
+	return SlidingExpiration(refreshFraction)
+}
+
+type Revocation struct {
+	RevocationChecker
+}
+
+func (r Revocation) configureSessionManager(c *managerConfig) {
+	c.revocationChecker = r.RevocationChecker
+}
+
+// WithRevocationChecker sets the RevocationChecker that is consulted for every
+// existing session that is loaded.
+//
+// A revoked session is replaced by a new session when it is loaded;
+// Session.Revoked will return true. This allows sessions kept in the cookie by
+// CookieStore, which have no server state, to be revoked.
+func WithRevocationChecker(checker RevocationChecker) SessionManagerOption {
+	return Revocation{checker}
+}
+
+type KeepMetadata bool
+
+func (k KeepMetadata) configureSessionManager(c *managerConfig) {
+	c.metadata = bool(k)
+}
+
+// WithMetadata keeps metadata about each session alongside the session values.
+//
+// The metadata is available from Session.Metadata and includes when the
+// session was created and last saved, the IP address and user agent of the
+// request that created it, and the key ID of the codec that encoded it.
+//
+// The metadata is also kept when timeouts, sliding expiration, or a
+// RevocationChecker are configured, which rely on it, but the IP address and
+// user agent of the client are only recorded with WithMetadata. The subject
+// set with Session.SetSubject is only kept across requests with metadata.
+//
+// Sessions that were saved without metadata are still loaded once it is kept,
+// but sessions saved with metadata cannot be loaded by a manager that does not
+// keep it.
+func WithMetadata() SessionManagerOption {
+	return KeepMetadata(true)
+}
+
+type ClientIP func(r *http.Request) string
+
+func (f ClientIP) configureSessionManager(c *managerConfig) {
+	c.clientIP = f
+}
+
+// WithClientIP sets the function used to find the IP address of the client for
+// the session metadata. The default is RemoteAddrIP.
+//
+// Applications behind a proxy should return the address the proxy forwarded;
+// only trust forwarding headers that the proxy sets.
+func WithClientIP(clientIP func(r *http.Request) string) SessionManagerOption {
+	return ClientIP(clientIP)
+}
+
+type TransportOption struct {
+	Transport
+}
+
+func (t TransportOption) configureSessionManager(c *managerConfig) {
+	c.transport = t.Transport
+}
+
+// WithTransport sets the Transport that carries the session value to and from
+// clients. The default is CookieTransport.
+//
+// Use a HeaderTransport, such as NewBearerTransport, for API clients that do
+// not use cookies; the same codecs and stores are used for every transport.
+// Values are only split across chunked cookies by transports that implement
+// NamedTransport, such as CookieTransport.
+func WithTransport(transport Transport) SessionManagerOption {
+	return TransportOption{transport}
+}
@@ -1,11 +1,13 @@
 package sessions
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -259,3 +261,801 @@ func TestSessionManager_Save(t *testing.T) {
 		})
 	}
 }
+
+func TestSessionManager_Registry(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		withRegistry bool
+		wantSame     bool
+		wantSaveErr  error
+	}
+
+	tests := map[string]testCase{
+		"with_registry": {
+			withRegistry: true,
+			wantSame:     true,
+		},
+		"without_registry": {
+			withRegistry: false,
+			wantSame:     false,
+			wantSaveErr:  ErrNoRegistry,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			manager := NewSessionManager[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				CookieStore{},
+				NewCodec(RandomBytes(32)),
+			)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.withRegistry {
+				req = req.WithContext(ContextWithRegistry(req.Context()))
+			}
+			ctx := req.Context()
+
+			// Act
+			first, err := manager.Get(req)
+			assert.NoError(t, err)
+			second, err := manager.Get(req)
+			assert.NoError(t, err)
+			err = Save(httptest.NewRecorder(), req)
+
+			// Assert
+			assert.Equal(t, tc.wantSame, first == second)
+			assert.ErrorIs(t, err, tc.wantSaveErr)
+			// the request is never replaced in place
+			assert.Equal(t, ctx, req.Context())
+			if tc.withRegistry {
+				assert.True(t, ContextWithRegistry(ctx) == ctx)
+			}
+		})
+	}
+}
+
+func TestSessionManager_Reissue(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		encodeBy         Codec
+		wantNeedsReissue bool
+		wantCookies      int
+	}
+
+	primary := NewCodec(RandomBytes(32))
+	retired := NewCodec(RandomBytes(32))
+
+	tests := map[string]testCase{
+		"primary_key": {
+			encodeBy:         primary,
+			wantNeedsReissue: false,
+			wantCookies:      0,
+		},
+		"retired_key": {
+			encodeBy:         retired,
+			wantNeedsReissue: true,
+			wantCookies:      1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			manager := NewSessionManager[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				CookieStore{},
+				primary, retired,
+			)
+			value, err := tc.encodeBy.Encode("session", sessionData{Value: "session-value"})
+			assert.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "session", Value: string(value)})
+			req = req.WithContext(ContextWithRegistry(req.Context()))
+			resp := httptest.NewRecorder()
+
+			// Act
+			session, err := manager.Get(req)
+			assert.NoError(t, err)
+			needsReissue := session.NeedsReissue()
+			err = SaveReissued(resp, req)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantNeedsReissue, needsReissue)
+			assert.False(t, session.NeedsReissue())
+			cookies := resp.Result().Cookies()
+			assert.Len(t, cookies, tc.wantCookies)
+			if len(cookies) > 0 {
+				var dst sessionData
+				assert.NoError(t, primary.Decode("session", []byte(cookies[0].Value), &dst))
+				assert.Equal(t, "session-value", dst.Value)
+			}
+		})
+	}
+}
+
+func TestSessionManager_DirtyTracking(t *testing.T) {
+	type sessionData struct {
+		Flash
+		Value string
+	}
+
+	type testCase struct {
+		setupCookie  func(codec Codec) *http.Cookie
+		setupSession func(s *Session[sessionData])
+		wantCookies  int
+	}
+
+	codec := NewCodec(RandomBytes(32))
+	existing := func(values sessionData) func(codec Codec) *http.Cookie {
+		return func(codec Codec) *http.Cookie {
+			value, _ := codec.Encode("session", &values)
+			return &http.Cookie{Name: "session", Value: string(value)}
+		}
+	}
+
+	tests := map[string]testCase{
+		"new_session": {
+			wantCookies: 1,
+		},
+		"unchanged_session": {
+			setupCookie: existing(sessionData{Value: "session-value"}),
+			wantCookies: 0,
+		},
+		"changed_session": {
+			setupCookie: existing(sessionData{Value: "session-value"}),
+			setupSession: func(s *Session[sessionData]) {
+				s.Values.Value = "new-value"
+			},
+			wantCookies: 1,
+		},
+		"changed_options": {
+			setupCookie: existing(sessionData{Value: "session-value"}),
+			setupSession: func(s *Session[sessionData]) {
+				s.Persist(60)
+			},
+			wantCookies: 1,
+		},
+		"expired_session": {
+			setupCookie: existing(sessionData{Value: "session-value"}),
+			setupSession: func(s *Session[sessionData]) {
+				s.Expire()
+			},
+			wantCookies: 1,
+		},
+		"loaded_flash": {
+			setupCookie: existing(func() sessionData {
+				v := sessionData{Value: "session-value"}
+				v.Add("key", "message")
+				return v
+			}()),
+			wantCookies: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				CookieStore{},
+				[]Codec{codec},
+				WithDirtyTracking(),
+			)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.setupCookie != nil {
+				req.AddCookie(tc.setupCookie(codec))
+			}
+			req = req.WithContext(ContextWithRegistry(req.Context()))
+			session, err := manager.Get(req)
+			assert.NoError(t, err)
+			if tc.setupSession != nil {
+				tc.setupSession(session)
+			}
+			resp := httptest.NewRecorder()
+
+			// Act
+			err = Save(resp, req)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, resp.Result().Cookies(), tc.wantCookies)
+
+			// saving again without changes does nothing
+			resp = httptest.NewRecorder()
+			assert.NoError(t, session.Save(resp, req))
+			assert.Len(t, resp.Result().Cookies(), 0)
+		})
+	}
+}
+
+func TestSessionManager_Timeouts(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		setupValues  func(now time.Time) any
+		managerOpts  []SessionManagerOption
+		wantTimedOut bool
+		wantValue    string
+		wantCreated  func(now time.Time) time.Time
+	}
+
+	now := time.Unix(1700000000, 0)
+	envelope := func(created, active time.Duration) func(now time.Time) any {
+		return func(now time.Time) any {
+			return sessionEnvelope[sessionData]{
+				SessionValues: sessionData{Value: "session-value"},
+				SessionMetadata: &sessionMetadata{
+					CreatedAt:    now.Add(-created).Unix(),
+					LastActiveAt: now.Add(-active).Unix(),
+				},
+			}
+		}
+	}
+
+	tests := map[string]testCase{
+		"active_session": {
+			setupValues:  envelope(time.Hour, time.Minute),
+			managerOpts:  []SessionManagerOption{WithIdleTimeout(30 * time.Minute), WithAbsoluteTimeout(12 * time.Hour)},
+			wantTimedOut: false,
+			wantValue:    "session-value",
+			wantCreated:  func(now time.Time) time.Time { return now.Add(-time.Hour) },
+		},
+		"idle_session": {
+			setupValues:  envelope(time.Hour, 31*time.Minute),
+			managerOpts:  []SessionManagerOption{WithIdleTimeout(30 * time.Minute), WithAbsoluteTimeout(12 * time.Hour)},
+			wantTimedOut: true,
+			wantCreated:  func(now time.Time) time.Time { return now },
+		},
+		"old_session": {
+			setupValues:  envelope(13*time.Hour, time.Minute),
+			managerOpts:  []SessionManagerOption{WithIdleTimeout(30 * time.Minute), WithAbsoluteTimeout(12 * time.Hour)},
+			wantTimedOut: true,
+			wantCreated:  func(now time.Time) time.Time { return now },
+		},
+		"legacy_session": {
+			setupValues: func(time.Time) any {
+				return sessionData{Value: "session-value"}
+			},
+			managerOpts:  []SessionManagerOption{WithIdleTimeout(30 * time.Minute)},
+			wantTimedOut: false,
+			wantValue:    "session-value",
+			wantCreated:  func(now time.Time) time.Time { return now },
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			codec := NewCodec(RandomBytes(32))
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				CookieStore{},
+				[]Codec{codec},
+				tc.managerOpts...,
+			)
+			manager.(*sessionManager[sessionData]).nowFn = func() time.Time { return now }
+			value, err := codec.Encode("session", tc.setupValues(now))
+			assert.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "session", Value: string(value)})
+
+			// Act
+			session, err := manager.Get(req)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantTimedOut, session.TimedOut())
+			assert.Equal(t, tc.wantTimedOut, session.IsNew)
+			assert.Equal(t, tc.wantValue, session.Values.Value)
+			assert.Equal(t, tc.wantCreated(now), session.CreatedAt())
+
+			// saving slides the idle window
+			later := now.Add(10 * time.Minute)
+			manager.(*sessionManager[sessionData]).nowFn = func() time.Time { return later }
+			resp := httptest.NewRecorder()
+			assert.NoError(t, session.Save(resp, req))
+			assert.Equal(t, later, session.LastActiveAt())
+			assert.Equal(t, tc.wantCreated(now), session.CreatedAt())
+
+			var saved sessionEnvelope[sessionData]
+			assert.NoError(t, codec.Decode("session", []byte(resp.Result().Cookies()[0].Value), &saved))
+			assert.Equal(t, later.Unix(), saved.SessionMetadata.LastActiveAt)
+		})
+	}
+}
+
+func TestSessionManager_SlidingExpiration(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		elapsed      time.Duration
+		setupSession func(s *Session[sessionData])
+		wantCookies  int
+	}
+
+	now := time.Unix(1700000000, 0)
+
+	tests := map[string]testCase{
+		"recently_saved": {
+			elapsed:     time.Hour,
+			wantCookies: 0,
+		},
+		"refresh_due": {
+			elapsed:     13 * time.Hour,
+			wantCookies: 1,
+		},
+		"changed_session": {
+			elapsed: time.Hour,
+			setupSession: func(s *Session[sessionData]) {
+				s.Values.Value = "new-value"
+			},
+			wantCookies: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			codec := NewCodec(RandomBytes(32))
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 86400},
+				CookieStore{},
+				[]Codec{codec},
+				WithSlidingExpiration(0.5),
+			)
+			manager.(*sessionManager[sessionData]).nowFn = func() time.Time { return now }
+			value, err := codec.Encode("session", sessionEnvelope[sessionData]{
+				SessionValues: sessionData{Value: "session-value"},
+				SessionMetadata: &sessionMetadata{
+					CreatedAt:    now.Add(-tc.elapsed).Unix(),
+					LastActiveAt: now.Add(-tc.elapsed).Unix(),
+				},
+			})
+			assert.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "session", Value: string(value)})
+			session, err := manager.Get(req)
+			assert.NoError(t, err)
+			if tc.setupSession != nil {
+				tc.setupSession(session)
+			}
+			resp := httptest.NewRecorder()
+
+			// Act
+			err = session.Save(resp, req)
+
+			// Assert
+			assert.NoError(t, err)
+			cookies := resp.Result().Cookies()
+			assert.Len(t, cookies, tc.wantCookies)
+			if len(cookies) > 0 {
+				assert.Equal(t, 86400, cookies[0].MaxAge)
+				assert.Equal(t, now, session.LastActiveAt())
+			}
+		})
+	}
+}
+
+func TestSessionManager_Revocation(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		revoke      func(rl *MemoryRevocationList, s *Session[sessionData]) error
+		wantRevoked bool
+	}
+
+	tests := map[string]testCase{
+		"not_revoked": {
+			wantRevoked: false,
+		},
+		"revoked_token": {
+			revoke: func(rl *MemoryRevocationList, s *Session[sessionData]) error {
+				return rl.Revoke(context.Background(), s.TokenID(), time.Now().Add(time.Hour))
+			},
+			wantRevoked: true,
+		},
+		"revoked_subject": {
+			revoke: func(rl *MemoryRevocationList, s *Session[sessionData]) error {
+				return rl.RevokeSubject(context.Background(), s.Subject(), time.Now().Add(time.Second))
+			},
+			wantRevoked: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			rl := NewMemoryRevocationList()
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				CookieStore{},
+				[]Codec{NewCodec(RandomBytes(32))},
+				WithRevocationChecker(rl),
+			)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			session, err := manager.Get(req)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, session.TokenID())
+			session.Values.Value = "session-value"
+			session.SetSubject("user")
+			resp := httptest.NewRecorder()
+			assert.NoError(t, session.Save(resp, req))
+			if tc.revoke != nil {
+				assert.NoError(t, tc.revoke(rl, session))
+			}
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(resp.Result().Cookies()[0])
+
+			// Act
+			loaded, err := manager.Get(req)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantRevoked, loaded.Revoked())
+			assert.Equal(t, tc.wantRevoked, loaded.IsNew)
+			if !tc.wantRevoked {
+				assert.Equal(t, "session-value", loaded.Values.Value)
+				assert.Equal(t, session.TokenID(), loaded.TokenID())
+			} else {
+				assert.Empty(t, loaded.Values.Value)
+				assert.NotEqual(t, session.TokenID(), loaded.TokenID())
+			}
+		})
+	}
+}
+
+func TestSessionManager_RevokedSubjectLogin(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		subject      string
+		createdAt    time.Duration
+		revokeBefore time.Duration
+		login        bool
+		wantRevoked  bool
+	}
+
+	start := time.Unix(1700000000, 0)
+
+	tests := map[string]testCase{
+		"login_after_revocation": {
+			createdAt:    0,
+			revokeBefore: 5 * time.Minute,
+			login:        true,
+			wantRevoked:  false,
+		},
+		"logged_in_before_revocation": {
+			subject:      "user",
+			createdAt:    0,
+			revokeBefore: 5 * time.Minute,
+			wantRevoked:  true,
+		},
+		"issued_in_the_same_second": {
+			subject:      "user",
+			createdAt:    100 * time.Millisecond,
+			revokeBefore: 900 * time.Millisecond,
+			wantRevoked:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			ctx := context.Background()
+			now := start.Add(tc.createdAt)
+			rl := NewMemoryRevocationList()
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				NewMemoryStore(0),
+				[]Codec{NewCodec(RandomBytes(32))},
+				WithRevocationChecker(rl),
+			)
+			manager.(*sessionManager[sessionData]).nowFn = func() time.Time { return now }
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			session, err := manager.Get(req)
+			assert.NoError(t, err)
+			session.SetSubject(tc.subject)
+			resp := httptest.NewRecorder()
+			assert.NoError(t, session.Save(resp, req))
+			assert.NoError(t, rl.RevokeSubject(ctx, "user", start.Add(tc.revokeBefore)))
+			now = start.Add(10 * time.Minute)
+			cookie := resp.Result().Cookies()[0]
+			if tc.login {
+				req = httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(cookie)
+				session, err = manager.Get(req)
+				assert.NoError(t, err)
+				session.Values.Value = "session-value"
+				session.SetSubject("user")
+				session.Regenerate()
+				resp = httptest.NewRecorder()
+				assert.NoError(t, session.Save(resp, req))
+				cookie = resp.Result().Cookies()[0]
+			}
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(cookie)
+
+			// Act
+			loaded, err := manager.Get(req)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantRevoked, loaded.Revoked())
+			assert.Equal(t, tc.wantRevoked, loaded.IsNew)
+			if tc.login {
+				assert.Equal(t, "user", loaded.Subject())
+				assert.Equal(t, "session-value", loaded.Values.Value)
+				assert.Equal(t, now, loaded.Metadata().IssuedAt)
+			}
+		})
+	}
+}
+
+func TestSessionManager_Metadata(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		managerOpts   []SessionManagerOption
+		wantIP        string
+		wantUserAgent string
+	}
+
+	tests := map[string]testCase{
+		"remote_addr": {
+			managerOpts:   []SessionManagerOption{WithMetadata()},
+			wantIP:        "192.0.2.1",
+			wantUserAgent: "test-agent",
+		},
+		"client_ip": {
+			managerOpts: []SessionManagerOption{
+				WithMetadata(),
+				WithClientIP(func(r *http.Request) string {
+					return r.Header.Get("X-Forwarded-For")
+				}),
+			},
+			wantIP:        "198.51.100.7",
+			wantUserAgent: "test-agent",
+		},
+		"client_not_recorded_without_option": {
+			managerOpts: []SessionManagerOption{WithIdleTimeout(time.Hour)},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			codec := NewCodec(RandomBytes(32), WithKeyID("k1"))
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				CookieStore{},
+				[]Codec{codec},
+				tc.managerOpts...,
+			)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("User-Agent", "test-agent")
+			req.Header.Set("X-Forwarded-For", "198.51.100.7")
+			session, err := manager.Get(req)
+			assert.NoError(t, err)
+			session.SetSubject("user")
+			resp := httptest.NewRecorder()
+			assert.NoError(t, session.Save(resp, req))
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("User-Agent", "other-agent")
+			req.AddCookie(resp.Result().Cookies()[0])
+
+			// Act
+			loaded, err := manager.Get(req)
+
+			// Assert
+			assert.NoError(t, err)
+			metadata := loaded.Metadata()
+			assert.Equal(t, tc.wantIP, metadata.IP)
+			assert.Equal(t, tc.wantUserAgent, metadata.UserAgent)
+			assert.Equal(t, "k1", metadata.KeyID)
+			assert.Equal(t, "user", metadata.Subject)
+			assert.Equal(t, session.CreatedAt(), metadata.CreatedAt)
+			assert.False(t, metadata.LastActiveAt.IsZero())
+		})
+	}
+}
+
+func TestSessionManager_MetadataNotKept(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore(0)
+	codec := NewCodec(RandomBytes(32))
+	manager := NewSessionManager[sessionData](CookieOptions{Name: "session", MaxAge: 3600}, store, codec)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	session, err := manager.Get(req)
+	assert.NoError(t, err)
+	session.Values.Value = "value"
+	session.SetSubject("user")
+
+	// Act
+	err = session.Save(httptest.NewRecorder(), req)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, Metadata{}, session.Metadata())
+	ids, err := store.SubjectSessions(ctx, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{session.ID()}, ids)
+	// the values are stored as they are, without the metadata envelope
+	var stored map[string]any
+	assert.NoError(t, codec.Decode("session", store.sessions[session.ID()].data, &stored))
+	assert.Equal(t, map[string]any{"Value": "value"}, stored)
+}
+
+func TestSessionManager_ListSessions(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		store   func(t *testing.T) Store
+		wantErr error
+	}
+
+	tests := map[string]testCase{
+		"file_system_store": {
+			store: func(t *testing.T) Store {
+				return NewFileSystemStore(t.TempDir(), 0)
+			},
+		},
+		"memory_store": {
+			store: func(t *testing.T) Store {
+				return NewMemoryStore(0)
+			},
+		},
+		"cookie_store": {
+			store: func(t *testing.T) Store {
+				return CookieStore{}
+			},
+			wantErr: ErrListNotSupported,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			ctx := context.Background()
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				tc.store(t),
+				[]Codec{NewCodec(RandomBytes(32))},
+				WithMetadata(),
+			)
+			login := func(subject, value string) *Session[sessionData] {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("User-Agent", value)
+				session, err := manager.Get(req)
+				assert.NoError(t, err)
+				session.Values.Value = value
+				session.SetSubject(subject)
+				assert.NoError(t, session.Save(httptest.NewRecorder(), req))
+				return session
+			}
+			for _, value := range []string{"a", "b", "c"} {
+				login("user", value)
+			}
+			other := login("other", "d")
+
+			// Act
+			first, next, err := ListSessions(ctx, manager, "user", "", 2)
+
+			// Assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.ErrorIs(t, DeleteSession(ctx, manager, "user", other.ID()), tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, first, 2)
+			assert.NotEmpty(t, next)
+			second, last, err := ListSessions(ctx, manager, "user", next, 2)
+			assert.NoError(t, err)
+			assert.Len(t, second, 1)
+			assert.Empty(t, last)
+
+			all := append(first, second...)
+			values := make([]string, 0, len(all))
+			for _, session := range all {
+				values = append(values, session.Values.Value)
+				assert.Equal(t, session.Values.Value, session.Metadata.UserAgent)
+				assert.Equal(t, "user", session.Metadata.Subject)
+			}
+			assert.ElementsMatch(t, []string{"a", "b", "c"}, values)
+
+			assert.ErrorIs(t, DeleteSession(ctx, manager, "user", other.ID()), ErrSessionNotFound)
+			assert.NoError(t, DeleteSession(ctx, manager, "user", all[0].ID))
+			remaining, _, err := ListSessions(ctx, manager, "user", "", 0)
+			assert.NoError(t, err)
+			assert.Len(t, remaining, 2)
+		})
+	}
+}
+
+func TestSessionManager_LoadFromValue(t *testing.T) {
+	type sessionData struct {
+		Count int
+	}
+
+	type testCase struct {
+		store Store
+	}
+
+	tests := map[string]testCase{
+		"cookie_store": {
+			store: CookieStore{},
+		},
+		"chunked_cookie_store": {
+			store: NewCookieStore(WithChunkSize(20)),
+		},
+		"file_system_store": {
+			store: NewFileSystemStore(t.TempDir(), 0),
+		},
+		"memory_store": {
+			store: NewMemoryStore(0),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			ctx := ContextWithClient(context.Background(), "192.0.2.1", "worker")
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				tc.store,
+				[]Codec{NewCodec(RandomBytes(32))},
+				WithMetadata(),
+			)
+			session, err := LoadFromValue(ctx, manager, "")
+			assert.NoError(t, err)
+			session.Values.Count = 1
+			cookies, err := session.Encode(ctx)
+			assert.NoError(t, err)
+			assert.Len(t, cookies, 1)
+
+			// Act
+			loaded, err := LoadFromValue(context.Background(), manager, cookies[0].Value)
+			assert.NoError(t, err)
+			loaded.Expire()
+			deleted, err := loaded.Encode(context.Background())
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, session.IsNew)
+			assert.False(t, loaded.IsNew)
+			assert.Equal(t, 1, loaded.Values.Count)
+			assert.Equal(t, "192.0.2.1", loaded.Metadata().IP)
+			assert.Equal(t, "worker", loaded.Metadata().UserAgent)
+			assert.Equal(t, "session", cookies[0].Name)
+			assert.Equal(t, 3600, cookies[0].MaxAge)
+			assert.Len(t, deleted, 1)
+			assert.Negative(t, deleted[0].MaxAge)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package sessions
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Metadata is the information the manager keeps about a session alongside the
+// session values.
+type Metadata struct {
+	// CreatedAt is the time the session was created
+	CreatedAt time.Time
+	// IssuedAt is the time the session was created, last regenerated, or
+	// associated with another subject
+	IssuedAt time.Time
+	// LastActiveAt is the time the session was last saved
+	LastActiveAt time.Time
+	// IP is the client IP address of the request that created the session;
+	// it is only recorded with WithMetadata
+	IP string
+	// UserAgent is the user agent of the request that created the session; it
+	// is only recorded with WithMetadata
+	UserAgent string
+	// KeyID is the key ID of the codec that last encoded the session
+	KeyID string
+	// Subject is the subject the session has been associated with
+	Subject string
+}
+
+// sessionMetadata is kept by the manager alongside the session values.
+type sessionMetadata struct {
+	CreatedAt    int64  `json:"c"`
+	IssuedAt     int64  `json:"i,omitempty"`
+	LastActiveAt int64  `json:"a"`
+	Subject      string `json:"s,omitempty"`
+	TokenID      string `json:"t,omitempty"`
+	IP           string `json:"ip,omitempty"`
+	UserAgent    string `json:"ua,omitempty"`
+	KeyID        string `json:"k,omitempty"`
+	CSRFSecret   string `json:"x,omitempty"`
+}
+
+func newSessionMetadata(ctx context.Context, now time.Time) *sessionMetadata {
+	client, _ := ctx.Value(clientKey).(clientInfo)
+	return &sessionMetadata{
+		CreatedAt:    now.Unix(),
+		IssuedAt:     now.UnixNano(),
+		LastActiveAt: now.Unix(),
+		TokenID:      randomID(16),
+		IP:           client.ip,
+		UserAgent:    client.userAgent,
+	}
+}
+
+type clientInfo struct {
+	ip        string
+	userAgent string
+}
+
+const clientKey contextKey = 10913
+
+// ContextWithClient returns a copy of ctx that holds the IP address and user
+// agent of the client, which are recorded in the metadata of new sessions.
+//
+// SessionManager.Get records the client of the request itself; this is for
+// callers of LoadFromValue.
+func ContextWithClient(ctx context.Context, ip, userAgent string) context.Context {
+	return context.WithValue(ctx, clientKey, clientInfo{ip: ip, userAgent: userAgent})
+}
+
+// RemoteAddrIP returns the IP address of the client from the RemoteAddr of the
+// request.
+//
+// This is the default client IP function for session metadata. Use
+// WithClientIP when the application is behind a proxy.
+func RemoteAddrIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rotateCSRFSecret replaces the CSRF secret with a new random secret.
+func (m *sessionMetadata) rotateCSRFSecret() error {
+	secret := make([]byte, csrfSecretLength)
+	if _, err := io.ReadFull(crand.Reader, secret); err != nil {
+		return ErrGeneratingCSRFToken
+	}
+	m.CSRFSecret = base64.RawURLEncoding.EncodeToString(secret)
+	return nil
+}
+
+func (m sessionMetadata) metadata() Metadata {
+	return Metadata{
+		CreatedAt:    m.createdAt(),
+		IssuedAt:     m.issuedAt(),
+		LastActiveAt: m.lastActiveAt(),
+		IP:           m.IP,
+		UserAgent:    m.UserAgent,
+		KeyID:        m.KeyID,
+		Subject:      m.Subject,
+	}
+}
+
+func (m sessionMetadata) createdAt() time.Time {
+	return time.Unix(m.CreatedAt, 0)
+}
+
+// issuedAt returns the time the session was last issued, in nanoseconds so
+// that a session issued in the same second as a revocation is told apart.
+// Sessions saved without it were issued when they were created.
+func (m sessionMetadata) issuedAt() time.Time {
+	if m.IssuedAt == 0 {
+		return m.createdAt()
+	}
+	return time.Unix(0, m.IssuedAt)
+}
+
+func (m sessionMetadata) lastActiveAt() time.Time {
+	return time.Unix(m.LastActiveAt, 0)
+}
+
+// sessionEnvelope wraps the session values so that the metadata is encoded
+// and stored with them.
+//
+// V is *T when decoding and T when encoding so that the values are serialized
+// exactly as they are without an envelope.
+type sessionEnvelope[V any] struct {
+	SessionValues   V                `json:"__values"`
+	SessionMetadata *sessionMetadata `json:"__metadata"`
+}
+
+// envelope is used by SessionProxy.Decode to fall back to decoding values that
+// were encoded before the manager began to keep metadata.
+type envelope interface {
+	hasMetadata() bool
+	values() any
+}
+
+// holdsSecret keeps the codecs from compressing the CSRF secret together with
+// the session values.
+func (e sessionEnvelope[V]) holdsSecret() bool {
+	return e.SessionMetadata != nil && e.SessionMetadata.CSRFSecret != ""
+}
+
+func (e *sessionEnvelope[V]) hasMetadata() bool {
+	return e.SessionMetadata != nil
+}
+
+func (e *sessionEnvelope[V]) values() any {
+	return e.SessionValues
+}
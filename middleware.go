@@ -0,0 +1,88 @@
+package sessions
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// AutoSave returns a middleware that saves every session in the request
+// registry just before the response headers are written.
+//
+// Sessions are saved when the handler first calls WriteHeader, Write, or Flush,
+// or when the handler returns without writing a response. Handlers no longer
+// need to call Save themselves, and writing the body before saving will no
+// longer drop the session cookies.
+//
+// The headers cannot be changed once they are written, so errors from saving
+// the sessions are passed to the optional errorHandler for logging.
+//
+// Example:
+//
+//	mux := http.NewServeMux()
+//	handler := sessions.AutoSave(func(r *http.Request, err error) {
+//		log.Println("failed to save sessions:", err)
+//	})(mux)
+func AutoSave(errorHandler func(r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// add the registry now so the sessions added by the handler are visible here
+			r = withRegistry(r)
+
+			sw := &saveResponseWriter{
+				ResponseWriter: w,
+				save: func(w http.ResponseWriter) {
+					if err := Save(w, r); err != nil && errorHandler != nil {
+						errorHandler(r, err)
+					}
+				},
+			}
+			next.ServeHTTP(sw, r)
+			sw.saveOnce()
+		})
+	}
+}
+
+type saveResponseWriter struct {
+	http.ResponseWriter
+	save  func(w http.ResponseWriter)
+	saved bool
+}
+
+var _ http.Flusher = (*saveResponseWriter)(nil)
+var _ http.Hijacker = (*saveResponseWriter)(nil)
+
+func (w *saveResponseWriter) WriteHeader(statusCode int) {
+	w.saveOnce()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *saveResponseWriter) Write(b []byte) (int, error) {
+	w.saveOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *saveResponseWriter) Flush() {
+	w.saveOnce()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack hands the connection over to the caller; sessions that have not been
+// saved yet will not be saved.
+func (w *saveResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.saved = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the original ResponseWriter for use by http.ResponseController.
+func (w *saveResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *saveResponseWriter) saveOnce() {
+	if w.saved {
+		return
+	}
+	w.saved = true
+	w.save(w.ResponseWriter)
+}
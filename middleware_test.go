@@ -0,0 +1,113 @@
+package sessions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoSave(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		store       Store
+		handler     func(w http.ResponseWriter, r *http.Request)
+		wantCookies int
+		wantBody    string
+		wantFlushed bool
+		wantErr     error
+	}
+
+	codec := NewCodec(RandomBytes(32))
+
+	tests := map[string]testCase{
+		"write_body": {
+			store: CookieStore{},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("body"))
+			},
+			wantCookies: 1,
+			wantBody:    "body",
+		},
+		"write_header": {
+			store: CookieStore{},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			wantCookies: 1,
+		},
+		"no_write": {
+			store:       CookieStore{},
+			handler:     func(w http.ResponseWriter, r *http.Request) {},
+			wantCookies: 1,
+		},
+		"flush": {
+			store: CookieStore{},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, http.NewResponseController(w).Flush())
+			},
+			wantCookies: 1,
+			wantFlushed: true,
+		},
+		"hijack_not_supported": {
+			store: CookieStore{},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _, err := http.NewResponseController(w).Hijack()
+				assert.ErrorIs(t, err, http.ErrNotSupported)
+			},
+			wantCookies: 0,
+		},
+		"save_error": {
+			store: &stubStore{
+				saveFn: func(ctx context.Context, proxy *SessionProxy) error {
+					return assert.AnError
+				},
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("body"))
+			},
+			wantBody: "body",
+			wantErr:  assert.AnError,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			manager := NewSessionManager[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				tc.store,
+				codec,
+			)
+			var gotErr error
+			handler := AutoSave(func(r *http.Request, err error) {
+				gotErr = err
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				session, err := manager.Get(r)
+				assert.NoError(t, err)
+				session.Values.Value = "session-value"
+				tc.handler(w, r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			resp := httptest.NewRecorder()
+
+			// Act
+			handler.ServeHTTP(resp, req)
+
+			// Assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, gotErr, tc.wantErr)
+			} else {
+				assert.NoError(t, gotErr)
+			}
+			assert.Len(t, resp.Result().Cookies(), tc.wantCookies)
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+			assert.Equal(t, tc.wantFlushed, resp.Flushed)
+		})
+	}
+}
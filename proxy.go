@@ -11,10 +11,54 @@ type SessionProxy struct {
 	ID      string
 	Values  any
 	IsNew   bool
-	req     *http.Request
-	resp    http.ResponseWriter
 	codecs  []Codec
 	options *CookieOptions
+	// decodedBy is the codec that last decoded a value
+	decodedBy Codec
+	// reissue is set when a value was decoded by a codec other than the first
+	reissue bool
+	// subject is the subject the session belongs to
+	subject string
+	// singleValue is set when the transport carries a single value rather than
+	// a value for each name, so the value cannot be split across chunks
+	singleValue bool
+	// incoming returns the values the client sent, by cookie name
+	incoming func(name string) (string, bool)
+	// outgoing sends the values to the client
+	outgoing func(value EncodedValue) error
+}
+
+// EncodedValue is an encoded value that is to be sent to the client, with the
+// attributes of the cookie that carries it.
+//
+// A negative MaxAge asks the client to delete the value.
+type EncodedValue struct {
+	Name        string
+	Value       string
+	Path        string
+	Domain      string
+	MaxAge      int
+	Expires     time.Time
+	Secure      bool
+	HttpOnly    bool
+	Partitioned bool
+	SameSite    http.SameSite
+}
+
+// Cookie returns the value as an http.Cookie.
+func (v EncodedValue) Cookie() *http.Cookie {
+	return &http.Cookie{
+		Name:        v.Name,
+		Value:       v.Value,
+		Path:        v.Path,
+		Domain:      v.Domain,
+		MaxAge:      v.MaxAge,
+		Expires:     v.Expires,
+		Secure:      v.Secure,
+		HttpOnly:    v.HttpOnly,
+		Partitioned: v.Partitioned,
+		SameSite:    v.SameSite,
+	}
 }
 
 // Decode will decode the data into the dst value.
@@ -30,15 +74,51 @@ type SessionProxy struct {
 //	}
 //
 // Useful destinations are the Values and ID fields of the SessionProxy.
+//
+// Values that carry a key ID are decoded by the codec with the matching KeyID
+// only. All other values are decoded by trying each codec in turn.
 func (sp *SessionProxy) Decode(data []byte, dst any) error {
 	if len(sp.codecs) == 0 {
 		return ErrNoCodecs
 	}
 
+	if env, ok := dst.(envelope); ok {
+		err := sp.decode(data, dst)
+		if err == nil && env.hasMetadata() {
+			return nil
+		}
+		// the values were saved before the manager kept metadata
+		if legacyErr := sp.decode(data, env.values()); legacyErr == nil {
+			return nil
+		}
+		if err == nil {
+			err = ErrDeserializeFailed
+		}
+		return err
+	}
+
+	return sp.decode(data, dst)
+}
+
+func (sp *SessionProxy) decode(data []byte, dst any) error {
+	if keyID, _, ok := splitKeyID(data); ok && sp.hasKeyIDs() {
+		for i, codec := range sp.codecs {
+			if identifier, ok := codec.(KeyIdentifier); ok && identifier.KeyID() == keyID {
+				if err := codec.Decode(sp.options.Name, data, dst); err != nil {
+					return err
+				}
+				sp.decoded(i)
+				return nil
+			}
+		}
+		return ErrKeyIDNotFound
+	}
+
 	var errs []error
-	for _, codec := range sp.codecs {
+	for i, codec := range sp.codecs {
 		err := codec.Decode(sp.options.Name, data, dst)
 		if err == nil {
+			sp.decoded(i)
 			return nil
 		}
 		errs = append(errs, err)
@@ -47,6 +127,40 @@ func (sp *SessionProxy) Decode(data []byte, dst any) error {
 	return errors.Join(errs...)
 }
 
+// KeyID returns the key ID of the codec that decoded the session.
+//
+// An empty string is returned if nothing was decoded or the codec does not
+// have a key ID.
+func (sp *SessionProxy) KeyID() string {
+	if identifier, ok := sp.decodedBy.(KeyIdentifier); ok {
+		return identifier.KeyID()
+	}
+	return ""
+}
+
+// NeedsReissue returns true if any value was decoded by a codec other than the
+// first, meaning the session should be saved again to encode it with the
+// current key.
+func (sp *SessionProxy) NeedsReissue() bool {
+	return sp.reissue
+}
+
+func (sp *SessionProxy) decoded(index int) {
+	sp.decodedBy = sp.codecs[index]
+	if index > 0 {
+		sp.reissue = true
+	}
+}
+
+func (sp *SessionProxy) hasKeyIDs() bool {
+	for _, codec := range sp.codecs {
+		if identifier, ok := codec.(KeyIdentifier); ok && identifier.KeyID() != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // Encode will encode the src value into a byte slice.
 //
 // Codecs that have been configured for this session will be used.
@@ -80,21 +194,21 @@ func (sp *SessionProxy) Encode(src any) ([]byte, error) {
 //
 // The cookie will be deleted if the cookie is expired based on its MaxAge.
 func (sp *SessionProxy) Save(value string) error {
-	if sp.resp == nil {
+	return sp.saveCookie(sp.options.Name, value)
+}
+
+// Delete will delete the session cookie regardless of its MaxAge.
+func (sp *SessionProxy) Delete() error {
+	return sp.deleteCookie(sp.options.Name)
+}
+
+func (sp *SessionProxy) saveCookie(name, value string) error {
+	if sp.outgoing == nil {
 		return ErrNoResponseWriter
 	}
 
-	cookie := &http.Cookie{
-		Name:        sp.options.Name,
-		Value:       value,
-		Path:        sp.options.Path,
-		Domain:      sp.options.Domain,
-		MaxAge:      sp.options.MaxAge,
-		Secure:      sp.options.Secure,
-		HttpOnly:    sp.options.HttpOnly,
-		Partitioned: sp.options.Partitioned,
-		SameSite:    sp.options.SameSite,
-	}
+	cookie := sp.newValue(name, value)
+	cookie.MaxAge = sp.options.MaxAge
 
 	switch {
 	case sp.options.MaxAge > 0:
@@ -108,37 +222,81 @@ func (sp *SessionProxy) Save(value string) error {
 		// noop; cookie will expire when the browser is closed
 	}
 
-	http.SetCookie(sp.resp, cookie)
-	return nil
+	return sp.writeCookie(cookie)
 }
 
-// Delete will delete the session cookie regardless of its MaxAge.
-func (sp *SessionProxy) Delete() error {
-	if sp.resp == nil {
+func (sp *SessionProxy) deleteCookie(name string) error {
+	if sp.outgoing == nil {
 		return ErrNoResponseWriter
 	}
 
-	cookie := &http.Cookie{
-		Name:        sp.options.Name,
-		Value:       "",
+	cookie := sp.newValue(name, "")
+	cookie.Expires = time.Unix(1, 0)
+	cookie.MaxAge = -1
+
+	return sp.writeCookie(cookie)
+}
+
+func (sp *SessionProxy) newValue(name, value string) EncodedValue {
+	return EncodedValue{
+		Name:        name,
+		Value:       value,
 		Path:        sp.options.Path,
 		Domain:      sp.options.Domain,
-		Expires:     time.Unix(1, 0),
-		MaxAge:      -1,
 		Secure:      sp.options.Secure,
 		HttpOnly:    sp.options.HttpOnly,
 		Partitioned: sp.options.Partitioned,
 		SameSite:    sp.options.SameSite,
 	}
+}
 
-	http.SetCookie(sp.resp, cookie)
-	return nil
+// requestCookie returns the value of the named cookie that the client sent.
+func (sp *SessionProxy) requestCookie(name string) (string, bool) {
+	if sp.incoming == nil {
+		return "", false
+	}
+	return sp.incoming(name)
+}
+
+func (sp *SessionProxy) writeCookie(value EncodedValue) error {
+	return sp.outgoing(value)
+}
+
+// requestReader returns a function that reads the values the client sent with
+// the request; cookies are read when the transport is nil.
+func requestReader(transport Transport, r *http.Request) func(name string) (string, bool) {
+	if transport == nil {
+		transport = CookieTransport{}
+	}
+	return func(name string) (string, bool) {
+		return transport.Read(r, name)
+	}
+}
+
+// responseWriter returns a function that sends the values to the client with
+// the response; cookies are set when the transport is nil.
+func responseWriter(transport Transport, w http.ResponseWriter) func(value EncodedValue) error {
+	if transport == nil {
+		transport = CookieTransport{}
+	}
+	return func(value EncodedValue) error {
+		return transport.Write(w, value.Cookie())
+	}
 }
 
 func (sp *SessionProxy) IsExpired() bool {
 	return sp.options.MaxAge < 0
 }
 
+// Subject returns the subject, such as a user ID, that the session belongs to.
+//
+// An empty string is returned if the session has not been associated with a
+// subject. Stores that implement SubjectIndexer should index the session for
+// the subject when it is saved.
+func (sp *SessionProxy) Subject() string {
+	return sp.subject
+}
+
 func (sp *SessionProxy) MaxAge() int {
 	return sp.options.MaxAge
 }
@@ -0,0 +1,111 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionProxy_Decode(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		codecs    []Codec
+		encodeBy  Codec
+		wantKeyID string
+		wantErr   error
+	}
+
+	key1 := RandomBytes(32)
+	key2 := RandomBytes(32)
+	src := sessionData{Value: "session-value"}
+
+	tests := map[string]testCase{
+		"selects_by_key_id": {
+			codecs: []Codec{
+				NewCodec(key1, WithKeyID("k1")),
+				NewCodec(key2, WithKeyID("k2")),
+			},
+			encodeBy:  NewCodec(key2, WithKeyID("k2")),
+			wantKeyID: "k2",
+		},
+		"legacy_value": {
+			codecs: []Codec{
+				NewCodec(key1, WithKeyID("k1")),
+				NewCodec(key2, WithKeyID("k2")),
+			},
+			encodeBy:  NewCodec(key2),
+			wantKeyID: "k2",
+		},
+		"unknown_key_id": {
+			codecs: []Codec{
+				NewCodec(key1, WithKeyID("k1")),
+			},
+			encodeBy: NewCodec(key2, WithKeyID("k2")),
+			wantErr:  ErrKeyIDNotFound,
+		},
+		"no_key_ids": {
+			codecs: []Codec{
+				NewCodec(key1),
+				NewCodec(key2),
+			},
+			encodeBy: NewCodec(key2),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			proxy := &SessionProxy{
+				options: &CookieOptions{Name: "session"},
+				codecs:  tc.codecs,
+			}
+			encoded, err := tc.encodeBy.Encode("session", src)
+			assert.NoError(t, err)
+
+			// Act
+			var dst sessionData
+			err = proxy.Decode(encoded, &dst)
+
+			// Assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, src, dst)
+				assert.Equal(t, tc.wantKeyID, proxy.KeyID())
+			}
+		})
+	}
+}
+
+func TestSessionProxy_Decode_SkipsOtherCodecs(t *testing.T) {
+	// Arrange
+	calls := 0
+	keyed := NewCodec(RandomBytes(32), WithKeyID("k1"))
+	proxy := &SessionProxy{
+		options: &CookieOptions{Name: "session"},
+		codecs: []Codec{
+			&stubCodec{
+				decodeFn: func(name string, src []byte, dst any) error {
+					calls++
+					return assert.AnError
+				},
+			},
+			keyed,
+		},
+	}
+	encoded, err := keyed.Encode("session", "value")
+	assert.NoError(t, err)
+
+	// Act
+	var dst string
+	err = proxy.Decode(encoded, &dst)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "value", dst)
+	assert.Equal(t, 0, calls)
+}
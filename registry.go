@@ -9,6 +9,7 @@ import (
 
 type registrySession interface {
 	Save(w http.ResponseWriter, r *http.Request) error
+	needsReissue() bool
 }
 
 type contextKey int
@@ -19,20 +20,37 @@ type registry struct {
 	sessions map[string]registrySession
 }
 
+// getRegistry returns the registry of the request, or nil if the context of the
+// request does not carry one.
 func getRegistry(r *http.Request) *registry {
-	ctx := r.Context()
+	return registryFromContext(r.Context())
+}
 
-	if reg := ctx.Value(sessionsKey); reg != nil {
-		return reg.(*registry)
+func registryFromContext(ctx context.Context) *registry {
+	if reg, ok := ctx.Value(sessionsKey).(*registry); ok {
+		return reg
 	}
+	return nil
+}
 
-	reg := &registry{
-		sessions: make(map[string]registrySession),
+// ContextWithRegistry returns a copy of ctx with a new, empty registry of
+// sessions; ctx is returned as is if it already carries one.
+//
+// Sessions from Get are kept in the registry, so that later calls to Get with a
+// request using the context return the same session, and Save and SaveReissued
+// can find them. AutoSave, CSRF.Middleware, and RememberMe.Middleware add the
+// registry themselves.
+//
+// Example:
+//
+//	r = r.WithContext(sessions.ContextWithRegistry(r.Context()))
+func ContextWithRegistry(ctx context.Context) context.Context {
+	if registryFromContext(ctx) != nil {
+		return ctx
 	}
-
-	*r = *r.WithContext(context.WithValue(ctx, sessionsKey, reg))
-
-	return reg
+	return context.WithValue(ctx, sessionsKey, &registry{
+		sessions: make(map[string]registrySession),
+	})
 }
 
 func (r *registry) get(name string) any {
@@ -43,9 +61,24 @@ func (r *registry) set(name string, session registrySession) {
 	r.sessions[name] = session
 }
 
+// withRegistry returns r, or a copy of r with a new registry if its context
+// does not carry one.
+func withRegistry(r *http.Request) *http.Request {
+	if registryFromContext(r.Context()) != nil {
+		return r
+	}
+	return r.WithContext(ContextWithRegistry(r.Context()))
+}
+
 // Save saves all sessions in the registry for the provided request.
+//
+// ErrNoRegistry is returned if the context of the request does not carry a
+// registry; see ContextWithRegistry.
 func Save(w http.ResponseWriter, r *http.Request) error {
 	reg := getRegistry(r)
+	if reg == nil {
+		return ErrNoRegistry
+	}
 
 	var errs []error
 	for name, session := range reg.sessions {
@@ -55,3 +88,28 @@ func Save(w http.ResponseWriter, r *http.Request) error {
 	}
 	return errors.Join(errs...)
 }
+
+// SaveReissued saves only the sessions in the registry for the provided request
+// that were decoded with a codec other than the first.
+//
+// This can be called on every request, for example from a middleware, to
+// rewrite sessions with the current key without saving every session.
+// ErrNoRegistry is returned if the context of the request does not carry a
+// registry.
+func SaveReissued(w http.ResponseWriter, r *http.Request) error {
+	reg := getRegistry(r)
+	if reg == nil {
+		return ErrNoRegistry
+	}
+
+	var errs []error
+	for name, session := range reg.sessions {
+		if !session.needsReissue() {
+			continue
+		}
+		if err := session.Save(w, r); err != nil {
+			errs = append(errs, errors.ErrInternalServerError.Wrapf(err, "registry: error while saving session: %q", name))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,381 @@
+package sessions
+
+import (
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stackus/errors"
+)
+
+// RememberMe keeps users logged in with a long-lived cookie after their
+// session has expired.
+//
+// The remember-me cookie holds a selector and a validator. The selector finds
+// the token in the RememberMeStore, which keeps only a hash of the validator.
+// Every time the token is used to restore a session the validator is
+// replaced. A validator that has already been replaced is evidence that the
+// cookie was stolen; every token and session of the subject is revoked so that
+// neither the user nor the thief can use them again.
+type RememberMe[T any] struct {
+	options CookieOptions
+	manager SessionManager[T]
+	store   RememberMeStore
+	restore func(r *http.Request, subject string, session *Session[T]) error
+	nowFn   func() time.Time
+}
+
+// RememberMeToken is a remember-me token as it is kept by a RememberMeStore.
+type RememberMeToken struct {
+	Selector      string
+	ValidatorHash []byte
+	// PreviousValidatorHash is accepted until the grace period after RotatedAt
+	// has passed, for requests that were sent at the same time
+	PreviousValidatorHash []byte
+	RotatedAt             time.Time
+	Subject               string
+	ExpiresAt             time.Time
+}
+
+// RememberMeStore keeps remember-me tokens.
+//
+// GetRememberMeToken returns ErrRememberMeTokenNotFound if there is no token
+// for the selector. DeleteRememberMeTokens deletes every token of the subject.
+//
+// ReplaceRememberMeToken saves the token only if the stored token for its
+// selector still has the validatorHash, checking and saving as a single
+// operation. It returns ErrRememberMeTokenChanged if the stored token has
+// another validator hash, and ErrRememberMeTokenNotFound if there is none.
+type RememberMeStore interface {
+	GetRememberMeToken(ctx context.Context, selector string) (RememberMeToken, error)
+	SaveRememberMeToken(ctx context.Context, token RememberMeToken) error
+	ReplaceRememberMeToken(ctx context.Context, token RememberMeToken, validatorHash []byte) error
+	DeleteRememberMeToken(ctx context.Context, selector string) error
+	DeleteRememberMeTokens(ctx context.Context, subject string) error
+}
+
+// rememberMeGracePeriod is how long the previous validator of a token is
+// accepted after it has been replaced.
+const rememberMeGracePeriod = 30 * time.Second
+
+// NewRememberMe returns a new RememberMe that sets the remember-me cookie with
+// the options and keeps the tokens in the store.
+//
+// The restore function is called to fill in the values of a new session for
+// the subject the token was issued to.
+func NewRememberMe[T any](options CookieOptions, manager SessionManager[T], store RememberMeStore, restore func(r *http.Request, subject string, session *Session[T]) error) *RememberMe[T] {
+	return &RememberMe[T]{
+		options: options,
+		manager: manager,
+		store:   store,
+		restore: restore,
+	}
+}
+
+// Remember issues a remember-me token for the subject and sets the remember-me
+// cookie. Any token in the remember-me cookie of the request is deleted.
+func (rm *RememberMe[T]) Remember(w http.ResponseWriter, r *http.Request, subject string) error {
+	if selector, _, ok := rm.requestToken(r); ok {
+		if err := rm.store.DeleteRememberMeToken(r.Context(), selector); err != nil {
+			return err
+		}
+	}
+
+	selector, err := rememberMeRandom()
+	if err != nil {
+		return err
+	}
+	validator, err := rememberMeRandom()
+	if err != nil {
+		return err
+	}
+
+	token := RememberMeToken{
+		Selector:      selector,
+		ValidatorHash: rememberMeHash(validator),
+		Subject:       subject,
+		ExpiresAt:     rm.now().Add(time.Duration(rm.options.MaxAge) * time.Second),
+	}
+	if err := rm.store.SaveRememberMeToken(r.Context(), token); err != nil {
+		return err
+	}
+
+	return rm.proxy(w, r).Save(selector + "." + validator)
+}
+
+// Forget deletes the token in the remember-me cookie of the request and
+// deletes the cookie.
+func (rm *RememberMe[T]) Forget(w http.ResponseWriter, r *http.Request) error {
+	if selector, _, ok := rm.requestToken(r); ok {
+		if err := rm.store.DeleteRememberMeToken(r.Context(), selector); err != nil {
+			return err
+		}
+	}
+	return rm.proxy(w, r).Delete()
+}
+
+// Restore returns the session for the request, restoring a new session from
+// the remember-me cookie when one is sent.
+//
+// A restored session is associated with the subject of the token, filled in by
+// the restore function, and Session.Remembered will return true. Sessions
+// that have been revoked are not restored. The validator in the remember-me
+// cookie is replaced each time.
+//
+// ErrRememberMeTokenStolen is returned along with the new session when a
+// validator that has already been replaced is used. Every token and session of
+// the subject is revoked with RevokeAll, which logs out whoever is still
+// holding them.
+func (rm *RememberMe[T]) Restore(w http.ResponseWriter, r *http.Request) (*Session[T], error) {
+	session, err := rm.manager.Get(r)
+	if err != nil || !session.IsNew || session.Revoked() || session.remembered {
+		return session, err
+	}
+
+	selector, validator, ok := rm.requestToken(r)
+	if !ok {
+		if _, err := r.Cookie(rm.options.Name); err == nil {
+			return session, rm.proxy(w, r).Delete()
+		}
+		return session, nil
+	}
+
+	token, err := rm.store.GetRememberMeToken(r.Context(), selector)
+	if errors.Is(err, ErrRememberMeTokenNotFound) {
+		return session, rm.proxy(w, r).Delete()
+	}
+	if err != nil {
+		return session, err
+	}
+
+	now := rm.now()
+	hash := rememberMeHash(validator)
+	switch {
+	case !now.Before(token.ExpiresAt):
+		if err := rm.store.DeleteRememberMeToken(r.Context(), selector); err != nil {
+			return session, err
+		}
+		return session, rm.proxy(w, r).Delete()
+	case hmac.Equal(hash, token.ValidatorHash):
+		err := rm.rotate(w, r, token, now)
+		if errors.Is(err, ErrRememberMeTokenChanged) {
+			// another request rotated the token first; the validator is now its
+			// previous validator and is accepted for the grace period
+			token, err = rm.store.GetRememberMeToken(r.Context(), selector)
+			if err == nil && !inGracePeriod(token, hash, now) {
+				err = ErrRememberMeTokenChanged
+			}
+		}
+		if err != nil {
+			return session, err
+		}
+	case inGracePeriod(token, hash, now):
+		// another request restored the session at the same time; the cookie has
+		// already been replaced by its response
+	default:
+		if err := rm.store.DeleteRememberMeToken(r.Context(), selector); err != nil {
+			return session, err
+		}
+		if err := rm.RevokeAll(r.Context(), token.Subject); err != nil {
+			return session, err
+		}
+		if err := rm.proxy(w, r).Delete(); err != nil {
+			return session, err
+		}
+		return session, ErrRememberMeTokenStolen
+	}
+
+	if err := rm.restore(r, token.Subject, session); err != nil {
+		return session, err
+	}
+	session.SetSubject(token.Subject)
+	session.remembered = true
+	return session, nil
+}
+
+// RevokeAll deletes every remember-me token of the subject and revokes every
+// session of the subject, such as after a password change or to log out
+// everywhere.
+//
+// Sessions are deleted when the store of the manager implements
+// SubjectIndexer, and revoked when the RevocationChecker of the manager
+// implements SubjectRevoker. Revoking the sessions alone is not enough, as the
+// remember-me tokens would restore them.
+func (rm *RememberMe[T]) RevokeAll(ctx context.Context, subject string) error {
+	if err := rm.store.DeleteRememberMeTokens(ctx, subject); err != nil {
+		return err
+	}
+	if sm, ok := rm.manager.(*sessionManager[T]); ok {
+		return sm.revokeSubject(ctx, subject)
+	}
+	return nil
+}
+
+// Middleware returns a middleware that restores sessions from the remember-me
+// cookie before calling next.
+//
+// Errors from restoring the session, including ErrRememberMeTokenStolen, are
+// passed to the optional errorHandler and the request continues with a new
+// session.
+func (rm *RememberMe[T]) Middleware(errorHandler func(r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// share the restored session with the handler
+			r = withRegistry(r)
+
+			if _, err := rm.Restore(w, r); err != nil && errorHandler != nil {
+				errorHandler(r, err)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rotate replaces the validator of the token and the remember-me cookie.
+func (rm *RememberMe[T]) rotate(w http.ResponseWriter, r *http.Request, token RememberMeToken, now time.Time) error {
+	validator, err := rememberMeRandom()
+	if err != nil {
+		return err
+	}
+
+	previous := token.ValidatorHash
+	token.PreviousValidatorHash = previous
+	token.ValidatorHash = rememberMeHash(validator)
+	token.RotatedAt = now
+	token.ExpiresAt = now.Add(time.Duration(rm.options.MaxAge) * time.Second)
+	if err := rm.store.ReplaceRememberMeToken(r.Context(), token, previous); err != nil {
+		return err
+	}
+
+	return rm.proxy(w, r).Save(token.Selector + "." + validator)
+}
+
+func (rm *RememberMe[T]) requestToken(r *http.Request) (string, string, bool) {
+	c, err := r.Cookie(rm.options.Name)
+	if err != nil {
+		return "", "", false
+	}
+	selector, validator, found := strings.Cut(c.Value, ".")
+	if !found || selector == "" || validator == "" {
+		return "", "", false
+	}
+	return selector, validator, true
+}
+
+// proxy returns a SessionProxy that is used to set the remember-me cookie.
+func (rm *RememberMe[T]) proxy(w http.ResponseWriter, r *http.Request) *SessionProxy {
+	return &SessionProxy{
+		incoming: requestReader(nil, r),
+		outgoing: responseWriter(nil, w),
+		options:  &rm.options,
+	}
+}
+
+func (rm *RememberMe[T]) now() time.Time {
+	if rm.nowFn != nil {
+		return rm.nowFn()
+	}
+	return time.Now()
+}
+
+// inGracePeriod reports if the hash is of the previous validator of the token,
+// which was replaced by a request sent at the same time.
+func inGracePeriod(token RememberMeToken, hash []byte, now time.Time) bool {
+	return hmac.Equal(hash, token.PreviousValidatorHash) && now.Before(token.RotatedAt.Add(rememberMeGracePeriod))
+}
+
+func rememberMeRandom() (string, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(crand.Reader, b); err != nil {
+		return "", ErrGeneratingRememberMeToken
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func rememberMeHash(validator string) []byte {
+	sum := sha256.Sum256([]byte(validator))
+	return sum[:]
+}
+
+// MemoryRememberMeStore is a RememberMeStore that keeps tokens in memory.
+type MemoryRememberMeStore struct {
+	mu     sync.RWMutex
+	tokens map[string]RememberMeToken
+}
+
+var _ RememberMeStore = (*MemoryRememberMeStore)(nil)
+
+// NewMemoryRememberMeStore returns a new, empty MemoryRememberMeStore.
+func NewMemoryRememberMeStore() *MemoryRememberMeStore {
+	return &MemoryRememberMeStore{
+		tokens: make(map[string]RememberMeToken),
+	}
+}
+
+func (s *MemoryRememberMeStore) GetRememberMeToken(_ context.Context, selector string) (RememberMeToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, exists := s.tokens[selector]
+	if !exists {
+		return RememberMeToken{}, ErrRememberMeTokenNotFound
+	}
+	return token, nil
+}
+
+func (s *MemoryRememberMeStore) SaveRememberMeToken(_ context.Context, token RememberMeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// forget the tokens that have expired
+	now := time.Now()
+	for selector, existing := range s.tokens {
+		if !now.Before(existing.ExpiresAt) {
+			delete(s.tokens, selector)
+		}
+	}
+	s.tokens[token.Selector] = token
+	return nil
+}
+
+func (s *MemoryRememberMeStore) ReplaceRememberMeToken(_ context.Context, token RememberMeToken, validatorHash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.tokens[token.Selector]
+	if !exists {
+		return ErrRememberMeTokenNotFound
+	}
+	if !hmac.Equal(existing.ValidatorHash, validatorHash) {
+		return ErrRememberMeTokenChanged
+	}
+	s.tokens[token.Selector] = token
+	return nil
+}
+
+func (s *MemoryRememberMeStore) DeleteRememberMeToken(_ context.Context, selector string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, selector)
+	return nil
+}
+
+func (s *MemoryRememberMeStore) DeleteRememberMeTokens(_ context.Context, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for selector, token := range s.tokens {
+		if token.Subject == subject {
+			delete(s.tokens, selector)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package sessions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRememberMe_Restore(t *testing.T) {
+	type sessionData struct {
+		Username string
+	}
+
+	type testCase struct {
+		cookies        func(remembered, rotated *http.Cookie) []*http.Cookie
+		afterGrace     bool
+		wantRemembered bool
+		wantErr        error
+		wantUsable     bool
+	}
+
+	tests := map[string]testCase{
+		"no_cookie": {
+			cookies: func(*http.Cookie, *http.Cookie) []*http.Cookie {
+				return nil
+			},
+			wantUsable: true,
+		},
+		"rotated_cookie": {
+			cookies: func(_, rotated *http.Cookie) []*http.Cookie {
+				return []*http.Cookie{rotated}
+			},
+			wantRemembered: true,
+			wantUsable:     true,
+		},
+		"concurrent_request": {
+			cookies: func(remembered, _ *http.Cookie) []*http.Cookie {
+				return []*http.Cookie{remembered}
+			},
+			wantRemembered: true,
+			wantUsable:     true,
+		},
+		"stolen_cookie": {
+			cookies: func(remembered, _ *http.Cookie) []*http.Cookie {
+				return []*http.Cookie{remembered}
+			},
+			afterGrace: true,
+			wantErr:    ErrRememberMeTokenStolen,
+			wantUsable: false,
+		},
+		"invalid_cookie": {
+			cookies: func(*http.Cookie, *http.Cookie) []*http.Cookie {
+				return []*http.Cookie{{Name: "remember", Value: "invalid"}}
+			},
+			wantUsable: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			now := time.Now()
+			store := NewMemoryRememberMeStore()
+			manager := NewSessionManager[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				CookieStore{},
+				NewCodec(RandomBytes(32)),
+			)
+			rm := NewRememberMe(
+				CookieOptions{Name: "remember", MaxAge: 86400 * 30},
+				manager,
+				store,
+				func(r *http.Request, subject string, session *Session[sessionData]) error {
+					session.Values.Username = "name-of-" + subject
+					return nil
+				},
+			)
+			rm.nowFn = func() time.Time { return now }
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			resp := httptest.NewRecorder()
+			assert.NoError(t, rm.Remember(resp, req, "user"))
+			remembered := resp.Result().Cookies()[0]
+
+			// the short session has expired; restore it once
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(remembered)
+			resp = httptest.NewRecorder()
+			_, err := rm.Restore(resp, req)
+			assert.NoError(t, err)
+			rotated := resp.Result().Cookies()[0]
+			assert.NotEqual(t, remembered.Value, rotated.Value)
+			if tc.afterGrace {
+				now = now.Add(time.Minute)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, cookie := range tc.cookies(remembered, rotated) {
+				req.AddCookie(cookie)
+			}
+			resp = httptest.NewRecorder()
+
+			// Act
+			session, err := rm.Restore(resp, req)
+
+			// Assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NotNil(t, session)
+			assert.Equal(t, tc.wantRemembered, session.Remembered())
+			if tc.wantRemembered {
+				assert.Equal(t, "user", session.Subject())
+				assert.Equal(t, "name-of-user", session.Values.Username)
+			}
+			_, err = store.GetRememberMeToken(context.Background(), rememberSelector(rotated))
+			assert.Equal(t, tc.wantUsable, err == nil)
+		})
+	}
+}
+
+func TestRememberMe_ConcurrentRestore(t *testing.T) {
+	type sessionData struct {
+		Username string
+	}
+
+	// Arrange
+	const requests = 2
+	store := newPausingRememberMeStore(requests)
+	manager := NewSessionManager[sessionData](
+		CookieOptions{Name: "session", MaxAge: 3600},
+		CookieStore{},
+		NewCodec(RandomBytes(32)),
+	)
+	rm := NewRememberMe(
+		CookieOptions{Name: "remember", MaxAge: 86400 * 30},
+		manager,
+		store,
+		func(r *http.Request, subject string, session *Session[sessionData]) error {
+			session.Values.Username = "name-of-" + subject
+			return nil
+		},
+	)
+	resp := httptest.NewRecorder()
+	assert.NoError(t, rm.Remember(resp, httptest.NewRequest(http.MethodGet, "/", nil), "user"))
+	remembered := resp.Result().Cookies()[0]
+
+	// Act
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, requests)
+	errs := make([]error, requests)
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(remembered)
+			responses[i] = httptest.NewRecorder()
+			session, err := rm.Restore(responses[i], req)
+			if err == nil && !session.Remembered() {
+				err = ErrRememberMeTokenNotFound
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	// Assert
+	var rotated []*http.Cookie
+	for i, resp := range responses {
+		assert.NoError(t, errs[i])
+		for _, cookie := range resp.Result().Cookies() {
+			if cookie.Name == "remember" {
+				rotated = append(rotated, cookie)
+			}
+		}
+	}
+	// only one of the requests replaces the validator
+	assert.Len(t, rotated, 1)
+	// the previous validator is accepted during the grace period, and the
+	// validator that replaced it afterwards
+	for _, cookie := range append([]*http.Cookie{remembered}, rotated...) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		session, err := rm.Restore(httptest.NewRecorder(), req)
+		assert.NoError(t, err)
+		assert.True(t, session.Remembered())
+	}
+}
+
+func TestRememberMe_RevokeAll(t *testing.T) {
+	type sessionData struct{}
+
+	type testCase struct {
+		revoke  func(rm *RememberMe[sessionData], stolen *http.Cookie) error
+		wantErr error
+	}
+
+	tests := map[string]testCase{
+		"stolen_cookie": {
+			revoke: func(rm *RememberMe[sessionData], stolen *http.Cookie) error {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.AddCookie(stolen)
+				_, err := rm.Restore(httptest.NewRecorder(), req)
+				return err
+			},
+			wantErr: ErrRememberMeTokenStolen,
+		},
+		"log_out_everywhere": {
+			revoke: func(rm *RememberMe[sessionData], _ *http.Cookie) error {
+				return rm.RevokeAll(context.Background(), "user")
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			now := time.Now()
+			store := NewMemoryRememberMeStore()
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				NewMemoryStore(0),
+				[]Codec{NewCodec(RandomBytes(32))},
+				WithMetadata(),
+			)
+			rm := NewRememberMe(
+				CookieOptions{Name: "remember", MaxAge: 86400 * 30},
+				manager,
+				store,
+				func(*http.Request, string, *Session[sessionData]) error { return nil },
+			)
+			rm.nowFn = func() time.Time { return now }
+			remember := func() *http.Cookie {
+				resp := httptest.NewRecorder()
+				assert.NoError(t, rm.Remember(resp, httptest.NewRequest(http.MethodGet, "/", nil), "user"))
+				return resp.Result().Cookies()[0]
+			}
+			stolen := remember()
+			otherDevice := remember()
+
+			// the thief restores a session with the cookie first
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(stolen)
+			resp := httptest.NewRecorder()
+			restored, err := rm.Restore(resp, req)
+			assert.NoError(t, err)
+			assert.NoError(t, restored.Save(resp, req))
+			var sessionCookie *http.Cookie
+			for _, cookie := range resp.Result().Cookies() {
+				if cookie.Name == "session" {
+					sessionCookie = cookie
+				}
+			}
+			assert.NotNil(t, sessionCookie)
+			now = now.Add(time.Minute)
+
+			// Act
+			err = tc.revoke(rm, stolen)
+
+			// Assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			_, err = store.GetRememberMeToken(context.Background(), rememberSelector(otherDevice))
+			assert.ErrorIs(t, err, ErrRememberMeTokenNotFound)
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(sessionCookie)
+			session, err := manager.Get(req)
+			assert.NoError(t, err)
+			assert.True(t, session.IsNew)
+			assert.Empty(t, session.Subject())
+		})
+	}
+}
+
+func TestRememberMe_Forget(t *testing.T) {
+	type sessionData struct{}
+
+	// Arrange
+	store := NewMemoryRememberMeStore()
+	rm := NewRememberMe(
+		CookieOptions{Name: "remember", MaxAge: 86400},
+		NewSessionManager[sessionData](CookieOptions{Name: "session"}, CookieStore{}, NewCodec(RandomBytes(32))),
+		store,
+		func(*http.Request, string, *Session[sessionData]) error { return nil },
+	)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	resp := httptest.NewRecorder()
+	assert.NoError(t, rm.Remember(resp, req, "user"))
+	remembered := resp.Result().Cookies()[0]
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(remembered)
+	resp = httptest.NewRecorder()
+
+	// Act
+	err := rm.Forget(resp, req)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, -1, resp.Result().Cookies()[0].MaxAge)
+	_, err = store.GetRememberMeToken(context.Background(), rememberSelector(remembered))
+	assert.ErrorIs(t, err, ErrRememberMeTokenNotFound)
+}
+
+func rememberSelector(cookie *http.Cookie) string {
+	selector, _, _ := strings.Cut(cookie.Value, ".")
+	return selector
+}
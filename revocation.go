@@ -0,0 +1,234 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/stackus/errors"
+)
+
+// RevocationChecker is consulted by the manager to find out if a session has
+// been revoked.
+//
+// The tokenID is the TokenID of the session, subject is the subject it has
+// been associated with, if any, and issuedAt is the time it was created, last
+// regenerated, or associated with the subject.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, tokenID, subject string, issuedAt time.Time) (bool, error)
+}
+
+// SubjectRevoker is implemented by revocation checkers that can revoke every
+// session of a subject issued before a point in time.
+type SubjectRevoker interface {
+	RevokeSubject(ctx context.Context, subject string, before time.Time) error
+}
+
+// MemoryRevocationList is a RevocationChecker that keeps the revoked sessions
+// in memory.
+//
+// Revoked token IDs are forgotten once they would have expired anyway.
+type MemoryRevocationList struct {
+	mu    sync.RWMutex
+	state revocationState
+	nowFn func() time.Time
+}
+
+// revocationState holds the revoked token IDs, with the time after which they
+// may be forgotten, and the time, in nanoseconds, before which every session of
+// a subject is revoked.
+type revocationState struct {
+	Tokens   map[string]int64 `json:"tokens"`
+	Subjects map[string]int64 `json:"subjects"`
+}
+
+var _ RevocationChecker = (*MemoryRevocationList)(nil)
+var _ RevocationChecker = (*FileRevocationList)(nil)
+var _ SubjectRevoker = (*MemoryRevocationList)(nil)
+var _ SubjectRevoker = (*FileRevocationList)(nil)
+
+// NewMemoryRevocationList returns a new, empty MemoryRevocationList.
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{}
+}
+
+// Revoke revokes the session with the token ID.
+//
+// The token ID is kept until expiresAt, which should be no earlier than the
+// time the session would expire.
+func (rl *MemoryRevocationList) Revoke(_ context.Context, tokenID string, expiresAt time.Time) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.state.revoke(tokenID, expiresAt, rl.now())
+	return nil
+}
+
+// RevokeSubject revokes every session of the subject issued before the time.
+func (rl *MemoryRevocationList) RevokeSubject(_ context.Context, subject string, before time.Time) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.state.revokeSubject(subject, before)
+	return nil
+}
+
+func (rl *MemoryRevocationList) IsRevoked(_ context.Context, tokenID, subject string, issuedAt time.Time) (bool, error) {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	return rl.state.isRevoked(tokenID, subject, issuedAt, rl.now()), nil
+}
+
+func (rl *MemoryRevocationList) now() time.Time {
+	if rl.nowFn != nil {
+		return rl.nowFn()
+	}
+	return time.Now()
+}
+
+// FileRevocationList is a RevocationChecker that keeps the revoked sessions in
+// a file, so they can be shared by several processes and survive restarts.
+//
+// The file is read again whenever it has been modified. Revocations are
+// written atomically, but concurrent revocations from different processes may
+// overwrite each other; revoke from a single process.
+type FileRevocationList struct {
+	path    string
+	mu      sync.RWMutex
+	state   revocationState
+	modTime time.Time
+}
+
+// NewFileRevocationList returns a new FileRevocationList that keeps the
+// revoked sessions in the file at path. The file is created when the first
+// session is revoked.
+func NewFileRevocationList(path string) *FileRevocationList {
+	return &FileRevocationList{path: path}
+}
+
+// Revoke revokes the session with the token ID.
+//
+// The token ID is kept until expiresAt, which should be no earlier than the
+// time the session would expire.
+func (rl *FileRevocationList) Revoke(_ context.Context, tokenID string, expiresAt time.Time) error {
+	return rl.update(func(state *revocationState) {
+		state.revoke(tokenID, expiresAt, time.Now())
+	})
+}
+
+// RevokeSubject revokes every session of the subject issued before the time.
+func (rl *FileRevocationList) RevokeSubject(_ context.Context, subject string, before time.Time) error {
+	return rl.update(func(state *revocationState) {
+		state.revokeSubject(subject, before)
+	})
+}
+
+func (rl *FileRevocationList) IsRevoked(_ context.Context, tokenID, subject string, issuedAt time.Time) (bool, error) {
+	if err := rl.reload(); err != nil {
+		return false, err
+	}
+
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	return rl.state.isRevoked(tokenID, subject, issuedAt, time.Now()), nil
+}
+
+// reload reads the file again if it has been modified since it was last read.
+func (rl *FileRevocationList) reload() error {
+	info, err := os.Stat(rl.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	rl.mu.RLock()
+	current := info.ModTime().Equal(rl.modTime)
+	rl.mu.RUnlock()
+	if current {
+		return nil
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.readLocked()
+}
+
+func (rl *FileRevocationList) readLocked() error {
+	info, err := os.Stat(rl.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := os.ReadFile(rl.path)
+	if err != nil {
+		return err
+	}
+	var state revocationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return errors.Join(ErrDeserializeFailed, err)
+	}
+	rl.state = state
+	rl.modTime = info.ModTime()
+	return nil
+}
+
+func (rl *FileRevocationList) update(fn func(state *revocationState)) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if err := rl.readLocked(); err != nil {
+		return err
+	}
+	fn(&rl.state)
+
+	data, err := json.Marshal(rl.state)
+	if err != nil {
+		return errors.Join(ErrSerializeFailed, err)
+	}
+	if err := writeFileAtomic(rl.path, data); err != nil {
+		return err
+	}
+	if info, err := os.Stat(rl.path); err == nil {
+		rl.modTime = info.ModTime()
+	}
+	return nil
+}
+
+func (s *revocationState) revoke(tokenID string, expiresAt, now time.Time) {
+	if s.Tokens == nil {
+		s.Tokens = make(map[string]int64)
+	}
+	// forget the token IDs of sessions that have expired anyway
+	for id, until := range s.Tokens {
+		if until < now.Unix() {
+			delete(s.Tokens, id)
+		}
+	}
+	s.Tokens[tokenID] = expiresAt.Unix()
+}
+
+func (s *revocationState) revokeSubject(subject string, before time.Time) {
+	if s.Subjects == nil {
+		s.Subjects = make(map[string]int64)
+	}
+	s.Subjects[subject] = before.UnixNano()
+}
+
+func (s *revocationState) isRevoked(tokenID, subject string, issuedAt, now time.Time) bool {
+	if until, exists := s.Tokens[tokenID]; tokenID != "" && exists && until >= now.Unix() {
+		return true
+	}
+	if before, exists := s.Subjects[subject]; subject != "" && exists && issuedAt.UnixNano() < before {
+		return true
+	}
+	return false
+}
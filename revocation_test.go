@@ -0,0 +1,68 @@
+package sessions
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocationList(t *testing.T) {
+	type revocationList interface {
+		RevocationChecker
+		Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+		RevokeSubject(ctx context.Context, subject string, before time.Time) error
+	}
+
+	type check struct {
+		tokenID  string
+		subject  string
+		issuedAt time.Time
+		want     bool
+	}
+
+	now := time.Now()
+	checks := map[string]check{
+		"revoked_token":          {tokenID: "revoked", issuedAt: now, want: true},
+		"forgotten_token":        {tokenID: "forgotten", issuedAt: now, want: false},
+		"other_token":            {tokenID: "other", issuedAt: now, want: false},
+		"revoked_subject":        {tokenID: "other", subject: "user", issuedAt: now.Add(-time.Hour), want: true},
+		"reissued_subject":       {tokenID: "other", subject: "user", issuedAt: now.Add(time.Hour), want: false},
+		"other_subject":          {tokenID: "other", subject: "other", issuedAt: now.Add(-time.Hour), want: false},
+		"empty_token_or_subject": {issuedAt: now.Add(-time.Hour), want: false},
+	}
+
+	lists := map[string]func(t *testing.T) (revocationList, RevocationChecker){
+		"memory": func(t *testing.T) (revocationList, RevocationChecker) {
+			rl := NewMemoryRevocationList()
+			return rl, rl
+		},
+		"file": func(t *testing.T) (revocationList, RevocationChecker) {
+			path := filepath.Join(t.TempDir(), "revoked.json")
+			// a second list reads the revocations from the file
+			return NewFileRevocationList(path), NewFileRevocationList(path)
+		},
+	}
+
+	for name, newList := range lists {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			ctx := context.Background()
+			rl, checker := newList(t)
+
+			// Act
+			assert.NoError(t, rl.Revoke(ctx, "revoked", now.Add(time.Hour)))
+			assert.NoError(t, rl.Revoke(ctx, "forgotten", now.Add(-time.Hour)))
+			assert.NoError(t, rl.RevokeSubject(ctx, "user", now))
+
+			// Assert
+			for name, c := range checks {
+				revoked, err := checker.IsRevoked(ctx, c.tokenID, c.subject, c.issuedAt)
+				assert.NoError(t, err, name)
+				assert.Equal(t, c.want, revoked, name)
+			}
+		})
+	}
+}
@@ -1,15 +1,30 @@
 package sessions
 
 import (
+	"context"
 	"net/http"
+	"time"
 )
 
 type Session[T any] struct {
-	Values   T
-	IsNew    bool
-	storeKey string
-	options  CookieOptions
-	manager  SessionManager[T]
+	Values     T
+	IsNew      bool
+	storeKey   string
+	keyID      string
+	reissue    bool
+	snapshot   *sessionSnapshot
+	regenerate bool
+	timedOut   bool
+	revoked    bool
+	remembered bool
+	metadata   *sessionMetadata
+	subject    string
+	options    CookieOptions
+	manager    SessionManager[T]
+	// incoming returns the values the client sent when the session was loaded
+	incoming func(name string) (string, bool)
+	// singleValue is set when the session was loaded from a single value
+	singleValue bool
 }
 
 // Expire will set the MaxAge of the session to -1, effectively deleting the
@@ -36,9 +51,168 @@ func (s *Session[T]) Persist(maxAge int) {
 	s.options.MaxAge = maxAge
 }
 
+// Regenerate will move the session to a new ID the next time it is saved.
+//
+// The session values are kept, the record for the previous ID is removed from
+// the store, and a new session cookie is set. This should be called whenever
+// the privileges of a session change, such as at login, to prevent session
+// fixation.
+//
+// The store must implement Regenerator, or ErrRegenerateNotSupported will be
+// returned when the session is saved. The CSRF secret of the session, if any,
+// is replaced.
+func (s *Session[T]) Regenerate() {
+	s.regenerate = true
+	if s.metadata != nil {
+		// tokens issued for the previous ID are no longer accepted; a new CSRF
+		// secret is created when the next token is issued
+		s.metadata.CSRFSecret = ""
+	}
+}
+
+func (s *Session[T]) csrfSecret() string {
+	if s.metadata == nil {
+		return ""
+	}
+	return s.metadata.CSRFSecret
+}
+
+// ID returns the ID the store has assigned to the session.
+//
+// Stores that keep the session values in the cookie, such as CookieStore, do
+// not assign IDs. The ID of a new session is assigned when it is first saved.
+func (s *Session[T]) ID() string {
+	return s.storeKey
+}
+
+// SetSubject associates the session with a subject, such as a user ID.
+//
+// The subject is kept with the session when the manager keeps session
+// metadata, such as with WithMetadata. Stores that implement SubjectIndexer
+// index the session for the subject when it is saved so that all sessions of
+// the subject can be revoked with RevokeAll.
+func (s *Session[T]) SetSubject(subject string) {
+	s.subject = subject
+}
+
+// Subject returns the subject the session has been associated with.
+func (s *Session[T]) Subject() string {
+	return s.subject
+}
+
+// TimedOut returns true if the session replaced a session which exceeded the
+// idle or absolute timeout of the manager.
+func (s *Session[T]) TimedOut() bool {
+	return s.timedOut
+}
+
+// Revoked returns true if the session replaced a session which was revoked by
+// the RevocationChecker of the manager.
+func (s *Session[T]) Revoked() bool {
+	return s.revoked
+}
+
+// Remembered returns true if the session was restored from a remember-me
+// cookie by RememberMe.
+func (s *Session[T]) Remembered() bool {
+	return s.remembered
+}
+
+// TokenID returns the random identifier that is kept with the session.
+//
+// Unlike ID, every session has a TokenID when the manager keeps session
+// metadata, including sessions kept in the cookie by CookieStore. A new
+// TokenID is assigned when the session is regenerated. Use it to revoke the
+// session with a RevocationChecker.
+//
+// An empty string is returned if the manager does not keep session metadata.
+func (s *Session[T]) TokenID() string {
+	if s.metadata == nil {
+		return ""
+	}
+	return s.metadata.TokenID
+}
+
+// Metadata returns the metadata the manager keeps about the session.
+//
+// The zero Metadata is returned if the manager does not keep session metadata.
+func (s *Session[T]) Metadata() Metadata {
+	if s.metadata == nil {
+		return Metadata{}
+	}
+	metadata := s.metadata.metadata()
+	metadata.Subject = s.subject
+	return metadata
+}
+
+// CreatedAt returns the time the session was created.
+//
+// The zero time is returned if the manager does not keep session metadata.
+func (s *Session[T]) CreatedAt() time.Time {
+	if s.metadata == nil {
+		return time.Time{}
+	}
+	return s.metadata.createdAt()
+}
+
+// LastActiveAt returns the time the session was last saved.
+//
+// The zero time is returned if the manager does not keep session metadata.
+func (s *Session[T]) LastActiveAt() time.Time {
+	if s.metadata == nil {
+		return time.Time{}
+	}
+	return s.metadata.lastActiveAt()
+}
+
+// KeyID returns the key ID of the codec that decoded the session.
+//
+// An empty string is returned for new sessions and for sessions decoded by a
+// codec without a key ID.
+func (s *Session[T]) KeyID() string {
+	return s.keyID
+}
+
+// NeedsReissue returns true if the session was decoded with a codec other than
+// the first one given to the manager.
+//
+// Saving the session will encode it with the first codec and clear the flag,
+// so the older keys can be retired once sessions have been reissued.
+func (s *Session[T]) NeedsReissue() bool {
+	return s.reissue
+}
+
 // Save will initiate the saving of the session to the store and the response.
 func (s *Session[T]) Save(w http.ResponseWriter, r *http.Request) error {
-	return s.manager.Save(w, r, s)
+	if err := s.manager.Save(w, r, s); err != nil {
+		return err
+	}
+	s.reissue = false
+	return nil
+}
+
+// Encode saves the session to the store and returns the encoded values, with
+// the attributes of their cookies, that are to be sent to the client.
+//
+// This is for callers without an http.ResponseWriter, such as sessions loaded
+// with LoadFromValue. No values are returned when the session did not need to
+// be saved. ErrValuesNotSupported is returned if the manager of the session was
+// not created by this package.
+func (s *Session[T]) Encode(ctx context.Context) ([]EncodedValue, error) {
+	sm, ok := s.manager.(*sessionManager[T])
+	if !ok {
+		return nil, ErrValuesNotSupported
+	}
+	values, err := sm.encode(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	s.reissue = false
+	return values, nil
+}
+
+func (s *Session[T]) needsReissue() bool {
+	return s.reissue
 }
 
 // Delete will delete the session from the store and the response.
@@ -46,5 +220,9 @@ func (s *Session[T]) Save(w http.ResponseWriter, r *http.Request) error {
 // This is a convenience method that sets the MaxAge of the session to -1 and saves the session.
 func (s *Session[T]) Delete(w http.ResponseWriter, r *http.Request) error {
 	s.options.MaxAge = -1
-	return s.manager.Save(w, r, s)
+	if err := s.manager.Save(w, r, s); err != nil {
+		return err
+	}
+	s.reissue = false
+	return nil
 }
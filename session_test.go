@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -576,3 +577,64 @@ func TestSession_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestSession_Regenerate(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	codec := NewCodec(RandomBytes(32))
+	options := CookieOptions{Name: "session", MaxAge: 3600}
+
+	t.Run("file_system_store", func(t *testing.T) {
+		// Arrange
+		store := NewFileSystemStore(t.TempDir(), 0)
+		manager := NewSessionManager[sessionData](options, store, codec)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		resp := httptest.NewRecorder()
+		session, err := manager.Get(req)
+		assert.NoError(t, err)
+		session.Values.Value = "session-value"
+		assert.NoError(t, session.Save(resp, req))
+		oldID := session.storeKey
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(resp.Result().Cookies()[0])
+		resp = httptest.NewRecorder()
+		session, err = manager.Get(req)
+		assert.NoError(t, err)
+		assert.Equal(t, oldID, session.storeKey)
+
+		// Act
+		session.Regenerate()
+		err = session.Save(resp, req)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NotEqual(t, oldID, session.storeKey)
+		_, err = os.Stat(store.fileName(oldID))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(resp.Result().Cookies()[0])
+		session, err = manager.Get(req)
+		assert.NoError(t, err)
+		assert.Equal(t, "session-value", session.Values.Value)
+	})
+
+	t.Run("not_supported", func(t *testing.T) {
+		// Arrange
+		manager := NewSessionManager[sessionData](options, &stubStore{}, codec)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		session, err := manager.Get(req)
+		assert.NoError(t, err)
+
+		// Act
+		session.Regenerate()
+		err = session.Save(httptest.NewRecorder(), req)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrRegenerateNotSupported)
+	})
+}
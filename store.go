@@ -1,13 +1,22 @@
 package sessions
 
 import (
+	"bytes"
 	"context"
 	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/base32"
+	"encoding/hex"
+	"hash/fnv"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Store interface {
@@ -16,15 +25,104 @@ type Store interface {
 	Save(ctx context.Context, proxy *SessionProxy) error
 }
 
-type CookieStore struct{}
+// Regenerator is implemented by stores that can move a session to a new ID.
+//
+// Regenerate is called in place of Save after Session.Regenerate has been
+// called. The store should save the session under a new ID, remove the record
+// for the previous ID, and set the session cookie for the new ID.
+type Regenerator interface {
+	Regenerate(ctx context.Context, proxy *SessionProxy) error
+}
+
+// Deleter is implemented by stores that can delete a session by its ID.
+//
+// Deleting a session that does not exist is not an error. The manager deletes
+// a session that has timed out or been revoked with Delete when the store does
+// not implement Regenerator.
+type Deleter interface {
+	Delete(ctx context.Context, id string) error
+}
+
+// Toucher is implemented by stores that can change when a session expires
+// without rewriting the session values.
+//
+// ErrSessionNotFound is returned if the session does not exist. Toucher is
+// for tooling outside of the manager; sliding expiration and the idle timeout
+// save the session again instead, as the time of the last activity is kept
+// with the session values.
+type Toucher interface {
+	Touch(ctx context.Context, id string, expiresAt time.Time) error
+}
+
+// Lister is implemented by stores that can list the IDs of the sessions that
+// have not expired. It is for tooling outside of the manager.
+type Lister interface {
+	List(ctx context.Context) ([]string, error)
+}
+
+// SubjectIndexer is implemented by stores that keep an index of the sessions
+// that belong to each subject, such as a user ID.
+//
+// Stores should index the session for SessionProxy.Subject when it is saved,
+// and remove it from the index when the session is deleted or moved to a new
+// ID. RevokeAll deletes every session that belongs to the subject.
+type SubjectIndexer interface {
+	SubjectSessions(ctx context.Context, subject string) ([]string, error)
+	RevokeAll(ctx context.Context, subject string) error
+}
+
+// SubjectLister is implemented by stores that can list the sessions that
+// belong to a subject, a page at a time, and delete one of them.
+//
+// ListSubject returns up to limit sessions, ordered by ID, with IDs after the
+// after cursor; all remaining sessions are returned when limit is zero or
+// less. The returned cursor is passed as after to fetch the next page, and is
+// empty when there are no more pages. The Data of each StoredSession is the
+// session values as they were encoded by SessionProxy.Encode.
+//
+// DeleteSubjectSession returns ErrSessionNotFound if the session does not
+// belong to the subject.
+type SubjectLister interface {
+	ListSubject(ctx context.Context, subject, after string, limit int) ([]StoredSession, string, error)
+	DeleteSubjectSession(ctx context.Context, subject, id string) error
+}
+
+// StoredSession is a session as it is kept by a store.
+type StoredSession struct {
+	ID   string
+	Data []byte
+}
+
+// Closer is implemented by stores that hold resources which should be released
+// when the store is no longer used. The manager does not close its store.
+type Closer interface {
+	Close() error
+}
+
+type CookieStore struct {
+	chunkSize int
+}
 
 var _ Store = (*CookieStore)(nil)
+var _ Regenerator = (*CookieStore)(nil)
+
+// NewCookieStore returns a new CookieStore that saves session values in the
+// session cookie, optionally configured with additional provided
+// CookieStoreOption options.
+func NewCookieStore(options ...CookieStoreOption) *CookieStore {
+	cs := &CookieStore{}
 
-func NewCookieStore() *CookieStore {
-	return &CookieStore{}
+	for _, option := range options {
+		option.configureCookieStore(cs)
+	}
+
+	return cs
 }
 
 func (cs CookieStore) Get(_ context.Context, proxy *SessionProxy, cookieValue string) error {
+	if cs.chunked(proxy) {
+		cookieValue += cs.readChunks(proxy)
+	}
 	return proxy.Decode([]byte(cookieValue), proxy.Values)
 }
 
@@ -39,33 +137,141 @@ func (cs CookieStore) Save(_ context.Context, proxy *SessionProxy) error {
 		return err
 	}
 
-	return proxy.Save(string(value))
+	if !cs.chunked(proxy) {
+		return proxy.Save(string(value))
+	}
+
+	chunks := []string{string(value)}
+	if !proxy.IsExpired() {
+		chunks = splitChunks(string(value), cs.chunkSize)
+	}
+	if len(chunks) > maxChunks {
+		return ErrEncodedLengthTooLong
+	}
+	for i := 1; i < len(chunks); i++ {
+		if err := proxy.saveCookie(chunkName(proxy.options.Name, i), chunks[i]); err != nil {
+			return err
+		}
+	}
+	if err := proxy.Save(chunks[0]); err != nil {
+		return err
+	}
+
+	// remove the chunks that are no longer needed
+	for i := len(chunks); i < maxChunks; i++ {
+		if _, exists := proxy.requestCookie(chunkName(proxy.options.Name, i)); !exists {
+			break
+		}
+		if err := proxy.deleteCookie(chunkName(proxy.options.Name, i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Regenerate saves the session; the session values are kept in the cookie so
+// there is no record to move.
+func (cs CookieStore) Regenerate(ctx context.Context, proxy *SessionProxy) error {
+	return cs.Save(ctx, proxy)
+}
+
+// chunked reports if the session value is split across chunks; values are
+// only split for transports that carry a separate value for each name.
+func (cs CookieStore) chunked(proxy *SessionProxy) bool {
+	return cs.chunkSize > 0 && !proxy.singleValue
+}
+
+// readChunks returns the values of the chunk cookies that follow the session
+// cookie in the request.
+func (cs CookieStore) readChunks(proxy *SessionProxy) string {
+	var value strings.Builder
+	for i := 1; i < maxChunks; i++ {
+		chunk, exists := proxy.requestCookie(chunkName(proxy.options.Name, i))
+		if !exists {
+			break
+		}
+		value.WriteString(chunk)
+	}
+	return value.String()
+}
+
+// maxChunks is the most cookies, including the session cookie, that a session
+// value is split across.
+const maxChunks = 32
+
+func splitChunks(value string, size int) []string {
+	chunks := make([]string, 0, len(value)/size+1)
+	for len(value) > size {
+		chunks = append(chunks, value[:size])
+		value = value[size:]
+	}
+	return append(chunks, value)
+}
+
+func chunkName(name string, index int) string {
+	return name + "." + strconv.Itoa(index)
 }
 
 type FileSystemStore struct {
 	root        string
 	maxFileSize int
+	shardDepth  int
+	shardWidth  int
 }
 
 var _ Store = (*FileSystemStore)(nil)
+var _ Regenerator = (*FileSystemStore)(nil)
+var _ Deleter = (*FileSystemStore)(nil)
+var _ Toucher = (*FileSystemStore)(nil)
+var _ Lister = (*FileSystemStore)(nil)
+var _ SubjectIndexer = (*FileSystemStore)(nil)
+var _ SubjectLister = (*FileSystemStore)(nil)
 
 const sessionFilePrefix = "session_"
+const subjectFilePrefix = "subject_"
 
-var fsMutex = &sync.Mutex{}
+// fsLocks are shared by every FileSystemStore so that stores sharing a root
+// still coordinate; each session file is guarded by one of the stripes.
+var fsLocks [256]sync.Mutex
 
-func NewFileSystemStore(root string, maxFileSize int) *FileSystemStore {
-	return &FileSystemStore{
+func fsLock(fileName string) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fileName))
+	return &fsLocks[h.Sum32()%uint32(len(fsLocks))]
+}
+
+// NewFileSystemStore returns a new FileSystemStore that saves session values in
+// files under root, optionally configured with additional provided
+// FileSystemStoreOption options.
+func NewFileSystemStore(root string, maxFileSize int, options ...FileSystemStoreOption) *FileSystemStore {
+	fs := &FileSystemStore{
 		root:        root,
 		maxFileSize: maxFileSize,
 	}
+
+	for _, option := range options {
+		option.configureFileSystemStore(fs)
+	}
+
+	return fs
 }
 
+// Get will load the session values for the session ID in the cookie.
+//
+// Sessions whose files have been deleted or have expired are treated as new
+// sessions.
 func (fs FileSystemStore) Get(_ context.Context, proxy *SessionProxy, cookieValue string) error {
 	if err := proxy.Decode([]byte(cookieValue), &proxy.ID); err != nil {
 		return err
 	}
 
 	data, err := fs.read(fs.fileName(proxy.ID))
+	if os.IsNotExist(err) {
+		proxy.ID = ""
+		proxy.IsNew = true
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -83,6 +289,9 @@ func (fs FileSystemStore) Save(_ context.Context, proxy *SessionProxy) error {
 		if err := fs.delete(fs.fileName(proxy.ID)); err != nil {
 			return err
 		}
+		if err := fs.unindex(proxy.Subject(), proxy.ID); err != nil {
+			return err
+		}
 		return proxy.Delete()
 	}
 
@@ -94,7 +303,11 @@ func (fs FileSystemStore) Save(_ context.Context, proxy *SessionProxy) error {
 	if err != nil {
 		return err
 	}
-	if err := fs.write(fs.fileName(proxy.ID), value); err != nil {
+	expiresAt := time.Now().Add(time.Duration(proxy.MaxAge()) * time.Second)
+	if err := fs.write(fs.fileName(proxy.ID), value, expiresAt); err != nil {
+		return err
+	}
+	if err := fs.index(proxy.Subject(), proxy.ID); err != nil {
 		return err
 	}
 
@@ -106,37 +319,821 @@ func (fs FileSystemStore) Save(_ context.Context, proxy *SessionProxy) error {
 	return proxy.Save(string(id))
 }
 
+// Regenerate saves the session in a file for a new ID and then deletes the file
+// for the previous ID.
+func (fs FileSystemStore) Regenerate(ctx context.Context, proxy *SessionProxy) error {
+	oldID := proxy.ID
+	if oldID == "" || proxy.MaxAge() <= 0 {
+		return fs.Save(ctx, proxy)
+	}
+
+	proxy.ID = ""
+	if err := fs.Save(ctx, proxy); err != nil {
+		proxy.ID = oldID
+		return err
+	}
+
+	if err := fs.delete(fs.fileName(oldID)); err != nil {
+		return err
+	}
+	return fs.unindex(proxy.Subject(), oldID)
+}
+
+// Delete removes the file for the session ID.
+//
+// ErrInvalidSessionID is returned if the ID could not have been assigned by
+// the store.
+func (fs FileSystemStore) Delete(_ context.Context, id string) error {
+	if !validSessionID(id) {
+		return ErrInvalidSessionID
+	}
+	return fs.delete(fs.fileName(id))
+}
+
+// SubjectSessions returns the IDs of the sessions that have been indexed for
+// the subject.
+//
+// Sessions that have since expired may be included until they are removed by
+// Cleanup.
+func (fs FileSystemStore) SubjectSessions(_ context.Context, subject string) ([]string, error) {
+	fileName := fs.subjectFileName(subject)
+	mu := fsLock(fileName)
+	mu.Lock()
+	defer mu.Unlock()
+
+	return fs.readIndex(fileName)
+}
+
+// RevokeAll removes the index for the subject and then deletes the files of
+// every session that was indexed for it.
+func (fs FileSystemStore) RevokeAll(ctx context.Context, subject string) error {
+	fileName := fs.subjectFileName(subject)
+	mu := fsLock(fileName)
+	mu.Lock()
+	ids, err := fs.readIndex(fileName)
+	if err == nil {
+		if err = os.Remove(fileName); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	// the session files may share a lock with the index file
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fs.delete(fs.fileName(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSubject returns a page of the sessions indexed for the subject that have
+// not expired.
+func (fs FileSystemStore) ListSubject(ctx context.Context, subject, after string, limit int) ([]StoredSession, string, error) {
+	ids, err := fs.SubjectSessions(ctx, subject)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(ids)
+
+	return pageSessions(ctx, ids, after, limit, func(id string) ([]byte, error) {
+		data, err := fs.read(fs.fileName(id))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return data, err
+	})
+}
+
+// DeleteSubjectSession deletes the file of a session indexed for the subject.
+func (fs FileSystemStore) DeleteSubjectSession(ctx context.Context, subject, id string) error {
+	if !validSessionID(id) {
+		return ErrInvalidSessionID
+	}
+	ids, err := fs.SubjectSessions(ctx, subject)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(ids, id) {
+		return ErrSessionNotFound
+	}
+
+	if err := fs.delete(fs.fileName(id)); err != nil {
+		return err
+	}
+	return fs.unindex(subject, id)
+}
+
+// Touch rewrites the expiry of the file for the session ID.
+//
+// ErrInvalidSessionID is returned if the ID could not have been assigned by
+// the store.
+func (fs FileSystemStore) Touch(_ context.Context, id string, expiresAt time.Time) error {
+	if !validSessionID(id) {
+		return ErrInvalidSessionID
+	}
+	fileName := fs.fileName(id)
+	mu := fsLock(fileName)
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	oldExpiresAt, value, ok := splitSessionFile(data)
+	if ok && !time.Now().Before(oldExpiresAt) {
+		return ErrSessionNotFound
+	}
+
+	return writeFileAtomic(fileName, append([]byte(strconv.FormatInt(expiresAt.Unix(), 10)+"|"), value...))
+}
+
+// List returns the IDs of the session files under root that have not expired.
+func (fs FileSystemStore) List(ctx context.Context) ([]string, error) {
+	now := time.Now()
+	var ids []string
+	err := filepath.WalkDir(fs.root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), sessionFilePrefix) {
+			return nil
+		}
+		expired, err := fs.expired(path, now)
+		if err != nil {
+			return err
+		}
+		if !expired {
+			ids = append(ids, strings.TrimPrefix(entry.Name(), sessionFilePrefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Cleanup removes the session files under root that have expired.
+//
+// The number of session files that were removed is returned. Files that were
+// written without an expiry are considered to be expired DefaultMaxAge seconds
+// after they were last modified. Temporary files left behind by writes that
+// were interrupted, such as by a crash, are removed once they are older than
+// staleTempFileAge.
+func (fs FileSystemStore) Cleanup(ctx context.Context) (int, error) {
+	now := time.Now()
+	removed := 0
+	err := filepath.WalkDir(fs.root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.IsDir() && isTempFile(entry.Name()) {
+			return removeStaleTempFile(path, now)
+		}
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), subjectFilePrefix) {
+			return fs.pruneIndex(path)
+		}
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), sessionFilePrefix) {
+			return nil
+		}
+		deleted, err := fs.deleteIfExpired(path, now)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			removed++
+		}
+		return nil
+	})
+
+	return removed, err
+}
+
+// RunCleanup calls Cleanup every interval until the context is canceled.
+//
+// The optional report func is called with the results of every cleanup.
+// RunCleanup blocks and should be started in its own goroutine.
+func (fs FileSystemStore) RunCleanup(ctx context.Context, interval time.Duration, report func(removed int, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			removed, err := fs.Cleanup(ctx)
+			if report != nil {
+				report(removed, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (fs FileSystemStore) fileName(id string) string {
-	return filepath.Clean(filepath.Join(fs.root, sessionFilePrefix+id))
+	parts := []string{fs.root}
+	for i := 0; i < fs.shardDepth && len(id) >= (i+1)*fs.shardWidth; i++ {
+		parts = append(parts, id[i*fs.shardWidth:(i+1)*fs.shardWidth])
+	}
+	return filepath.Clean(filepath.Join(append(parts, sessionFilePrefix+id)...))
+}
+
+// subjectFileName returns the name of the index file for the subject; the
+// subject is hashed so that any subject is a safe file name.
+func (fs FileSystemStore) subjectFileName(subject string) string {
+	sum := sha256.Sum256([]byte(subject))
+	return filepath.Join(fs.root, subjectFilePrefix+hex.EncodeToString(sum[:]))
+}
+
+// index adds the session ID to the index for the subject. The index is not
+// written when it already holds the ID.
+func (fs FileSystemStore) index(subject, id string) error {
+	if subject == "" {
+		return nil
+	}
+	return fs.updateIndex(fs.subjectFileName(subject), func(ids []string) ([]string, bool) {
+		if slices.Contains(ids, id) {
+			return ids, false
+		}
+		return append(ids, id), true
+	})
+}
+
+// unindex removes the session ID from the index for the subject.
+func (fs FileSystemStore) unindex(subject, id string) error {
+	if subject == "" || id == "" {
+		return nil
+	}
+	return fs.updateIndex(fs.subjectFileName(subject), func(ids []string) ([]string, bool) {
+		kept := slices.DeleteFunc(ids, func(existing string) bool {
+			return existing == id
+		})
+		return kept, len(kept) != len(ids)
+	})
+}
+
+// pruneIndex drops the IDs of sessions that no longer exist from the index
+// file.
+func (fs FileSystemStore) pruneIndex(fileName string) error {
+	return fs.updateIndex(fileName, func(ids []string) ([]string, bool) {
+		kept := slices.DeleteFunc(slices.Clone(ids), func(existing string) bool {
+			_, err := os.Stat(fs.fileName(existing))
+			return os.IsNotExist(err)
+		})
+		return kept, len(kept) != len(ids)
+	})
+}
+
+// updateIndex replaces the IDs in the index file with the IDs returned by
+// update; the file is only written when update reports a change.
+func (fs FileSystemStore) updateIndex(fileName string, update func(ids []string) ([]string, bool)) error {
+	mu := fsLock(fileName)
+	mu.Lock()
+	defer mu.Unlock()
+
+	ids, err := fs.readIndex(fileName)
+	if err != nil {
+		return err
+	}
+	ids, changed := update(ids)
+	if !changed {
+		return nil
+	}
+	if len(ids) == 0 {
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(fs.root, 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(fileName, []byte(strings.Join(ids, "\n")))
+}
+
+// readIndex returns the session IDs in the index file. The lock for the file
+// must be held.
+func (fs FileSystemStore) readIndex(fileName string) ([]string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(data), "\n"), nil
 }
 
 func (fs FileSystemStore) read(fileName string) ([]byte, error) {
-	fsMutex.Lock()
-	defer fsMutex.Unlock()
-	return os.ReadFile(fileName)
+	mu := fsLock(fileName)
+	mu.Lock()
+	defer mu.Unlock()
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt, value, ok := splitSessionFile(data)
+	if ok && !time.Now().Before(expiresAt) {
+		return nil, &os.PathError{Op: "read", Path: fileName, Err: os.ErrNotExist}
+	}
+
+	return value, nil
 }
 
-func (fs FileSystemStore) write(fileName string, data []byte) error {
+func (fs FileSystemStore) write(fileName string, data []byte, expiresAt time.Time) error {
 	// check data length against maxFileSize
 	if fs.maxFileSize > 0 && len(data) > fs.maxFileSize {
 		return ErrEncodedLengthTooLong
 	}
-	fsMutex.Lock()
-	defer fsMutex.Unlock()
-	return os.WriteFile(fileName, data, 0600)
+	data = append([]byte(strconv.FormatInt(expiresAt.Unix(), 10)+"|"), data...)
+	mu := fsLock(fileName)
+	mu.Lock()
+	defer mu.Unlock()
+	if fs.shardDepth > 0 {
+		if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(fileName, data)
 }
 
 func (fs FileSystemStore) delete(fileName string) error {
-	fsMutex.Lock()
-	defer fsMutex.Unlock()
+	mu := fsLock(fileName)
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (fs FileSystemStore) deleteIfExpired(fileName string, now time.Time) (bool, error) {
+	mu := fsLock(fileName)
+	mu.Lock()
+	defer mu.Unlock()
+
+	expired, err := fs.expiredLocked(fileName, now)
+	if err != nil || !expired {
+		return false, err
+	}
+
 	if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+func (fs FileSystemStore) expired(fileName string, now time.Time) (bool, error) {
+	mu := fsLock(fileName)
+	mu.Lock()
+	defer mu.Unlock()
+
+	return fs.expiredLocked(fileName, now)
+}
+
+// expiredLocked reports if the session file has expired; files that no longer
+// exist are not considered to be expired. The lock for the file must be held.
+func (fs FileSystemStore) expiredLocked(fileName string, now time.Time) (bool, error) {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	expiresAt, _, ok := splitSessionFile(data)
+	if !ok {
+		expiresAt = info.ModTime().Add(time.Duration(DefaultMaxAge) * time.Second)
+	}
+	return !now.Before(expiresAt), nil
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory and
+// then renames it over fileName, so readers only ever see a complete file.
+func writeFileAtomic(fileName string, data []byte) (err error) {
+	dir, base := filepath.Split(fileName)
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
+
+// staleTempFileAge is how long a temporary file written by writeFileAtomic
+// must go unmodified before Cleanup considers the write abandoned.
+const staleTempFileAge = time.Hour
+
+// isTempFile reports if the name is a temporary file written by
+// writeFileAtomic for a session or subject index file.
+func isTempFile(name string) bool {
+	return (strings.HasPrefix(name, "."+sessionFilePrefix) || strings.HasPrefix(name, "."+subjectFilePrefix)) &&
+		strings.Contains(name, ".tmp")
+}
+
+// removeStaleTempFile removes the temporary file if it has not been modified
+// for staleTempFileAge.
+func removeStaleTempFile(fileName string, now time.Time) error {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if now.Sub(info.ModTime()) < staleTempFileAge {
+		return nil
+	}
+	if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// splitSessionFile separates the expiry header from the encoded session value.
+//
+// Session files are written as "expiry|value" where expiry is a unix timestamp.
+// Files written by earlier versions of the store only contain the value.
+func splitSessionFile(data []byte) (time.Time, []byte, bool) {
+	header, value, found := bytes.Cut(data, []byte("|"))
+	if !found {
+		return time.Time{}, data, false
+	}
+	expiresAt, err := strconv.ParseInt(string(header), 10, 64)
+	if err != nil {
+		return time.Time{}, data, false
+	}
+	return time.Unix(expiresAt, 0), value, true
+}
+
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]memorySession
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	nowFn    func() time.Time
+}
+
+type memorySession struct {
+	data      []byte
+	expiresAt time.Time
+	subject   string
+}
+
+var _ Store = (*MemoryStore)(nil)
+var _ Regenerator = (*MemoryStore)(nil)
+var _ Deleter = (*MemoryStore)(nil)
+var _ Toucher = (*MemoryStore)(nil)
+var _ Lister = (*MemoryStore)(nil)
+var _ SubjectIndexer = (*MemoryStore)(nil)
+var _ SubjectLister = (*MemoryStore)(nil)
+var _ Closer = (*MemoryStore)(nil)
+
+// NewMemoryStore returns a new MemoryStore that keeps session values in memory.
+//
+// Sessions are kept for the MaxAge of the session cookie. If cleanupInterval is
+// greater than zero, a background janitor will remove expired sessions at that
+// interval until Close is called.
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	ms := &MemoryStore{
+		sessions: make(map[string]memorySession),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if cleanupInterval > 0 {
+		go ms.janitor(cleanupInterval)
+	} else {
+		close(ms.done)
+	}
+
+	return ms
+}
+
+// Get will load the session values for the session ID in the cookie.
+//
+// Sessions that are unknown to the store or have expired are treated as new
+// sessions.
+func (ms *MemoryStore) Get(_ context.Context, proxy *SessionProxy, cookieValue string) error {
+	if err := proxy.Decode([]byte(cookieValue), &proxy.ID); err != nil {
+		return err
+	}
+
+	ms.mu.RLock()
+	session, exists := ms.sessions[proxy.ID]
+	ms.mu.RUnlock()
+
+	if !exists || session.expired(ms.now()) {
+		proxy.ID = ""
+		proxy.IsNew = true
+		return nil
+	}
+
+	return proxy.Decode(session.data, proxy.Values)
+}
+
+func (ms *MemoryStore) New(_ context.Context, _ *SessionProxy) error {
+	// nothing to do
+	return nil
+}
+
+func (ms *MemoryStore) Save(_ context.Context, proxy *SessionProxy) error {
+	if proxy.MaxAge() <= 0 {
+		ms.delete(proxy.ID)
+		return proxy.Delete()
+	}
+
+	if proxy.ID == "" {
+		proxy.ID = randomID(32)
+	}
+
+	value, err := proxy.Encode(proxy.Values)
+	if err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	ms.sessions[proxy.ID] = memorySession{
+		data:      value,
+		expiresAt: ms.now().Add(time.Duration(proxy.MaxAge()) * time.Second),
+		subject:   proxy.Subject(),
+	}
+	ms.mu.Unlock()
+
+	id, err := proxy.Encode(proxy.ID)
+	if err != nil {
 		return err
 	}
+
+	return proxy.Save(string(id))
+}
+
+// Regenerate saves the session for a new ID and then deletes the session for
+// the previous ID.
+func (ms *MemoryStore) Regenerate(ctx context.Context, proxy *SessionProxy) error {
+	oldID := proxy.ID
+	if oldID == "" || proxy.MaxAge() <= 0 {
+		return ms.Save(ctx, proxy)
+	}
+
+	proxy.ID = ""
+	if err := ms.Save(ctx, proxy); err != nil {
+		proxy.ID = oldID
+		return err
+	}
+
+	ms.delete(oldID)
+	return nil
+}
+
+// Delete removes the session for the ID.
+func (ms *MemoryStore) Delete(_ context.Context, id string) error {
+	ms.delete(id)
+	return nil
+}
+
+// Touch changes when the session for the ID expires.
+func (ms *MemoryStore) Touch(_ context.Context, id string, expiresAt time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	session, exists := ms.sessions[id]
+	if !exists || session.expired(ms.now()) {
+		return ErrSessionNotFound
+	}
+	session.expiresAt = expiresAt
+	ms.sessions[id] = session
 	return nil
 }
 
+// List returns the IDs of the sessions that have not expired.
+func (ms *MemoryStore) List(_ context.Context) ([]string, error) {
+	now := ms.now()
+
+	ms.mu.RLock()
+	ids := make([]string, 0, len(ms.sessions))
+	for id, session := range ms.sessions {
+		if !session.expired(now) {
+			ids = append(ids, id)
+		}
+	}
+	ms.mu.RUnlock()
+
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// SubjectSessions returns the IDs of the sessions of the subject that have not
+// expired.
+func (ms *MemoryStore) SubjectSessions(_ context.Context, subject string) ([]string, error) {
+	now := ms.now()
+
+	ms.mu.RLock()
+	var ids []string
+	for id, session := range ms.sessions {
+		if session.subject == subject && !session.expired(now) {
+			ids = append(ids, id)
+		}
+	}
+	ms.mu.RUnlock()
+
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// RevokeAll deletes every session of the subject.
+func (ms *MemoryStore) RevokeAll(_ context.Context, subject string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for id, session := range ms.sessions {
+		if session.subject == subject {
+			delete(ms.sessions, id)
+		}
+	}
+	return nil
+}
+
+// ListSubject returns a page of the sessions of the subject that have not
+// expired.
+func (ms *MemoryStore) ListSubject(ctx context.Context, subject, after string, limit int) ([]StoredSession, string, error) {
+	ids, err := ms.SubjectSessions(ctx, subject)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pageSessions(ctx, ids, after, limit, func(id string) ([]byte, error) {
+		ms.mu.RLock()
+		defer ms.mu.RUnlock()
+		session, exists := ms.sessions[id]
+		if !exists || session.expired(ms.now()) {
+			return nil, nil
+		}
+		return session.data, nil
+	})
+}
+
+// DeleteSubjectSession deletes a session of the subject.
+func (ms *MemoryStore) DeleteSubjectSession(_ context.Context, subject, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	session, exists := ms.sessions[id]
+	if !exists || session.subject != subject {
+		return ErrSessionNotFound
+	}
+	delete(ms.sessions, id)
+	return nil
+}
+
+// Close stops the background janitor, if one is running.
+//
+// Sessions remain available after the store has been closed.
+func (ms *MemoryStore) Close() error {
+	ms.stopOnce.Do(func() {
+		close(ms.stop)
+	})
+	<-ms.done
+	return nil
+}
+
+func (ms *MemoryStore) janitor(interval time.Duration) {
+	defer close(ms.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ms.deleteExpired()
+		case <-ms.stop:
+			return
+		}
+	}
+}
+
+func (ms *MemoryStore) deleteExpired() int {
+	now := ms.now()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	removed := 0
+	for id, session := range ms.sessions {
+		if session.expired(now) {
+			delete(ms.sessions, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (ms *MemoryStore) delete(id string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.sessions, id)
+}
+
+func (ms *MemoryStore) now() time.Time {
+	if ms.nowFn != nil {
+		return ms.nowFn()
+	}
+	return time.Now()
+}
+
+func (s memorySession) expired(now time.Time) bool {
+	return !now.Before(s.expiresAt)
+}
+
 var base32RawStdEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
 
+// pageSessions loads a page of sessions from the sorted IDs for
+// SubjectLister.ListSubject. Sessions that load returns no data for are
+// skipped.
+func pageSessions(ctx context.Context, ids []string, after string, limit int, load func(id string) ([]byte, error)) ([]StoredSession, string, error) {
+	var page []StoredSession
+	for _, id := range ids {
+		if after != "" && id <= after {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		if limit > 0 && len(page) == limit {
+			return page, page[len(page)-1].ID, nil
+		}
+		data, err := load(id)
+		if err != nil {
+			return nil, "", err
+		}
+		if data != nil {
+			page = append(page, StoredSession{ID: id, Data: data})
+		}
+	}
+	return page, "", nil
+}
+
+// validSessionID reports if the ID only uses the characters of the IDs created
+// by randomID, so that it is safe to use as part of a file name.
+func validSessionID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if (c < 'A' || c > 'Z') && (c < '2' || c > '7') {
+			return false
+		}
+	}
+	return true
+}
+
 func randomID(length int) string {
 	k := make([]byte, length)
 	if _, err := io.ReadFull(crand.Reader, k); err != nil {
@@ -0,0 +1,66 @@
+package sessions
+
+// CookieStoreOption is an option for configuring a CookieStore.
+//
+// The following options are available:
+// - WithChunkSize: splits large session values across multiple cookies
+type CookieStoreOption interface {
+	configureCookieStore(*CookieStore)
+}
+
+type ChunkSize int
+
+func (s ChunkSize) configureCookieStore(cs *CookieStore) {
+	cs.chunkSize = max(int(s), 0)
+}
+
+// WithChunkSize splits session values that are longer than size across
+// multiple cookies.
+//
+// The first chunk is saved in the session cookie and the remaining chunks are
+// saved in cookies named after the session cookie with a numbered suffix;
+// "name.1", "name.2", and so on. Browsers limit each cookie to about 4096
+// bytes including its name and attributes, so a size around 3800 is a good
+// starting point.
+//
+// The codecs will still refuse to encode values longer than their MaxLength,
+// which will need to be raised with WithMaxLength to store larger values.
+func WithChunkSize(size int) CookieStoreOption {
+	return ChunkSize(size)
+}
+
+// FileSystemStoreOption is an option for configuring a FileSystemStore.
+//
+// The following options are available:
+// - WithSharding: spreads the session files across nested subdirectories
+type FileSystemStoreOption interface {
+	configureFileSystemStore(*FileSystemStore)
+}
+
+type Sharding struct {
+	Depth int
+	Width int
+}
+
+func (s Sharding) configureFileSystemStore(fs *FileSystemStore) {
+	if s.Depth <= 0 || s.Width <= 0 {
+		fs.shardDepth, fs.shardWidth = 0, 0
+		return
+	}
+	fs.shardDepth = s.Depth
+	fs.shardWidth = s.Width
+}
+
+// WithSharding spreads the session files across nested subdirectories of the
+// store root instead of keeping them all in a single directory.
+//
+// The subdirectories are named after the leading characters of the session ID;
+// depth sets how many levels of subdirectories are used and width sets how many
+// characters of the ID are used for each level. For example, a depth of 2 and a
+// width of 2 will save the session "ABCDEF" as "root/AB/CD/session_ABCDEF".
+//
+// Subdirectories are created as they are needed. Existing session files are not
+// moved when the layout is changed.
+func WithSharding(depth, width int) FileSystemStoreOption {
+	return Sharding{Depth: depth, Width: width}
+}
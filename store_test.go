@@ -1,9 +1,16 @@
 package sessions
 
 import (
+	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -64,8 +71,8 @@ func TestCookieStore_Get(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			resp := httptest.NewRecorder()
 			proxy := &SessionProxy{
-				req:  req,
-				resp: resp,
+				incoming: requestReader(nil, req),
+				outgoing: responseWriter(nil, resp),
 			}
 			if tc.setupProxy != nil {
 				tc.setupProxy(proxy)
@@ -113,8 +120,8 @@ func TestCookieStore_New(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			resp := httptest.NewRecorder()
 			proxy := &SessionProxy{
-				req:  req,
-				resp: resp,
+				incoming: requestReader(nil, req),
+				outgoing: responseWriter(nil, resp),
 			}
 			if tc.setupProxy != nil {
 				tc.setupProxy(proxy)
@@ -208,8 +215,8 @@ func TestCookieStore_Save(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			resp := httptest.NewRecorder()
 			proxy := &SessionProxy{
-				req:  req,
-				resp: resp,
+				incoming: requestReader(nil, req),
+				outgoing: responseWriter(nil, resp),
 			}
 			if tc.setupProxy != nil {
 				tc.setupProxy(proxy)
@@ -271,11 +278,46 @@ func TestFileSystemStore_Get(t *testing.T) {
 				if err != nil {
 					return err
 				}
-				return store.write(store.fileName(id), data)
+				return store.write(store.fileName(id), data, time.Now().Add(time.Hour))
 			},
 			cookieID:   "cookie_id",
 			wantValues: &testValues{Value: "cookie_value"},
 		},
+		"missing_file": {
+			setupProxy: func(proxy *SessionProxy) {
+				proxy.options = &CookieOptions{
+					MaxAge: 3600,
+				}
+				proxy.codecs = []Codec{
+					NewCodec(codecKey),
+				}
+				proxy.Values = new(testValues)
+			},
+			cookieID:   "cookie_id",
+			wantValues: new(testValues),
+			wantIsNew:  true,
+		},
+		"expired_file": {
+			setupProxy: func(proxy *SessionProxy) {
+				proxy.options = &CookieOptions{
+					MaxAge: 3600,
+				}
+				proxy.codecs = []Codec{
+					NewCodec(codecKey),
+				}
+				proxy.Values = new(testValues)
+			},
+			setupFile: func(store *FileSystemStore, proxy *SessionProxy, id string) error {
+				data, err := proxy.Encode(&testValues{Value: "cookie_value"})
+				if err != nil {
+					return err
+				}
+				return store.write(store.fileName(id), data, time.Now().Add(-time.Second))
+			},
+			cookieID:   "cookie_id",
+			wantValues: new(testValues),
+			wantIsNew:  true,
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -284,8 +326,8 @@ func TestFileSystemStore_Get(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			resp := httptest.NewRecorder()
 			proxy := &SessionProxy{
-				req:  req,
-				resp: resp,
+				incoming: requestReader(nil, req),
+				outgoing: responseWriter(nil, resp),
 			}
 			if tc.setupProxy != nil {
 				tc.setupProxy(proxy)
@@ -343,8 +385,8 @@ func TestFileSystemStore_New(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			resp := httptest.NewRecorder()
 			proxy := &SessionProxy{
-				req:  req,
-				resp: resp,
+				incoming: requestReader(nil, req),
+				outgoing: responseWriter(nil, resp),
 			}
 			if tc.setupProxy != nil {
 				tc.setupProxy(proxy)
@@ -402,7 +444,7 @@ func TestFileSystemStore_Save(t *testing.T) {
 				if err != nil {
 					return err
 				}
-				return store.write(store.fileName(id), data)
+				return store.write(store.fileName(id), data, time.Now().Add(time.Hour))
 			},
 			cookieID: "cookie_id",
 			wantCookies: []*http.Cookie{
@@ -463,8 +505,8 @@ func TestFileSystemStore_Save(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			resp := httptest.NewRecorder()
 			proxy := &SessionProxy{
-				req:  req,
-				resp: resp,
+				incoming: requestReader(nil, req),
+				outgoing: responseWriter(nil, resp),
 			}
 			if tc.setupProxy != nil {
 				tc.setupProxy(proxy)
@@ -499,3 +541,680 @@ func TestFileSystemStore_Save(t *testing.T) {
 		})
 	}
 }
+
+func TestMemoryStore_Get(t *testing.T) {
+	type testCase struct {
+		setupStore func(store *MemoryStore, proxy *SessionProxy) error
+		cookieID   string
+		wantValues any
+		wantID     string
+		wantIsNew  bool
+		wantErr    error
+	}
+
+	var codecKey = RandomBytes(32)
+
+	type testValues struct {
+		Value string
+	}
+
+	tests := map[string]testCase{
+		"existing_session": {
+			setupStore: func(store *MemoryStore, proxy *SessionProxy) error {
+				data, err := proxy.Encode(&testValues{Value: "cookie_value"})
+				if err != nil {
+					return err
+				}
+				store.sessions["cookie_id"] = memorySession{data: data, expiresAt: time.Now().Add(time.Hour)}
+				return nil
+			},
+			cookieID:   "cookie_id",
+			wantValues: &testValues{Value: "cookie_value"},
+			wantID:     "cookie_id",
+		},
+		"expired_session": {
+			setupStore: func(store *MemoryStore, proxy *SessionProxy) error {
+				data, err := proxy.Encode(&testValues{Value: "cookie_value"})
+				if err != nil {
+					return err
+				}
+				store.sessions["cookie_id"] = memorySession{data: data, expiresAt: time.Now().Add(-time.Second)}
+				return nil
+			},
+			cookieID:   "cookie_id",
+			wantValues: &testValues{},
+			wantIsNew:  true,
+		},
+		"unknown_session": {
+			cookieID:   "cookie_id",
+			wantValues: &testValues{},
+			wantIsNew:  true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			resp := httptest.NewRecorder()
+			proxy := &SessionProxy{
+				Values:   new(testValues),
+				incoming: requestReader(nil, req),
+				outgoing: responseWriter(nil, resp),
+				options:  &CookieOptions{MaxAge: 3600},
+				codecs:   []Codec{NewCodec(codecKey)},
+			}
+			store := NewMemoryStore(0)
+			if tc.setupStore != nil {
+				err := tc.setupStore(store, proxy)
+				assert.NoError(t, err)
+			}
+			cookieValue, _ := proxy.Encode(tc.cookieID)
+
+			// Act
+			err := store.Get(req.Context(), proxy, string(cookieValue))
+
+			// Assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.wantID, proxy.ID)
+				assert.Equal(t, tc.wantIsNew, proxy.IsNew)
+				assert.Equal(t, tc.wantValues, proxy.Values)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Save(t *testing.T) {
+	type testCase struct {
+		setupProxy  func(proxy *SessionProxy)
+		wantStored  bool
+		wantCookies []*http.Cookie
+		wantErr     error
+	}
+
+	var codecKey = RandomBytes(32)
+
+	type testValues struct {
+		Value string
+	}
+
+	tests := map[string]testCase{
+		"new_session": {
+			setupProxy: func(proxy *SessionProxy) {
+				proxy.Values = &testValues{Value: "cookie_value"}
+				proxy.options = &CookieOptions{
+					Name:   "session",
+					MaxAge: 3600,
+				}
+			},
+			wantStored: true,
+			wantCookies: []*http.Cookie{
+				{
+					Name:   "session",
+					Value:  "cookie_id",
+					MaxAge: 3600,
+				},
+			},
+		},
+		"deleted_session": {
+			setupProxy: func(proxy *SessionProxy) {
+				proxy.ID = "cookie_id"
+				proxy.Values = &testValues{Value: "cookie_value"}
+				proxy.options = &CookieOptions{
+					Name:   "session",
+					MaxAge: -1,
+				}
+			},
+			wantCookies: []*http.Cookie{
+				{
+					Name:   "session",
+					Value:  "",
+					MaxAge: -1,
+				},
+			},
+		},
+		"no_codecs": {
+			setupProxy: func(proxy *SessionProxy) {
+				proxy.Values = &testValues{Value: "cookie_value"}
+				proxy.options = &CookieOptions{
+					Name:   "session",
+					MaxAge: 3600,
+				}
+				proxy.codecs = nil
+			},
+			wantErr: ErrNoCodecs,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			resp := httptest.NewRecorder()
+			proxy := &SessionProxy{
+				incoming: requestReader(nil, req),
+				outgoing: responseWriter(nil, resp),
+				codecs:   []Codec{NewCodec(codecKey)},
+			}
+			if tc.setupProxy != nil {
+				tc.setupProxy(proxy)
+			}
+			store := NewMemoryStore(0)
+			store.sessions["cookie_id"] = memorySession{expiresAt: time.Now().Add(time.Hour)}
+
+			// Act
+			err := store.Save(req.Context(), proxy)
+
+			// Assert
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+				_, stored := store.sessions[proxy.ID]
+				assert.Equal(t, tc.wantStored, stored)
+				assert.Equal(t, len(tc.wantCookies), len(resp.Result().Cookies()))
+				if len(tc.wantCookies) > 0 {
+					want := tc.wantCookies[0]
+					got := resp.Result().Cookies()[0]
+					cookieValue := []byte{}
+					if want.Value != "" {
+						cookieValue, _ = proxy.Encode(proxy.ID)
+					}
+					assert.Equal(t, want.Name, got.Name)
+					assert.Equal(t, string(cookieValue), got.Value)
+					assert.Equal(t, want.MaxAge, got.MaxAge)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Janitor(t *testing.T) {
+	store := NewMemoryStore(time.Millisecond)
+	store.mu.Lock()
+	store.sessions["expired"] = memorySession{expiresAt: time.Now().Add(-time.Second)}
+	store.sessions["active"] = memorySession{expiresAt: time.Now().Add(time.Hour)}
+	store.mu.Unlock()
+
+	assert.Eventually(t, func() bool {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		_, exists := store.sessions["expired"]
+		return !exists
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(t, store.Close())
+	assert.NoError(t, store.Close())
+
+	_, exists := store.sessions["active"]
+	assert.True(t, exists)
+}
+
+func TestFileSystemStore_Cleanup(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	store := NewFileSystemStore(tmpDir, 0)
+
+	assert.NoError(t, store.write(store.fileName("active"), []byte("value"), time.Now().Add(time.Hour)))
+	assert.NoError(t, store.write(store.fileName("expired"), []byte("value"), time.Now().Add(-time.Second)))
+	assert.NoError(t, os.WriteFile(store.fileName("legacy_active"), []byte("value"), 0600))
+	assert.NoError(t, os.WriteFile(store.fileName("legacy_expired"), []byte("value"), 0600))
+	legacyTime := time.Now().Add(-time.Duration(DefaultMaxAge+1) * time.Second)
+	assert.NoError(t, os.Chtimes(store.fileName("legacy_expired"), legacyTime, legacyTime))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "unrelated"), []byte("value"), 0600))
+	assert.NoError(t, os.Chtimes(filepath.Join(tmpDir, "unrelated"), legacyTime, legacyTime))
+	staleTime := time.Now().Add(-2 * staleTempFileAge)
+	staleTemp := filepath.Join(tmpDir, ".session_stale.tmp123")
+	assert.NoError(t, os.WriteFile(staleTemp, []byte("value"), 0600))
+	assert.NoError(t, os.Chtimes(staleTemp, staleTime, staleTime))
+	staleIndexTemp := filepath.Join(tmpDir, ".subject_stale.tmp456")
+	assert.NoError(t, os.WriteFile(staleIndexTemp, []byte("value"), 0600))
+	assert.NoError(t, os.Chtimes(staleIndexTemp, staleTime, staleTime))
+	freshTemp := filepath.Join(tmpDir, ".session_fresh.tmp789")
+	assert.NoError(t, os.WriteFile(freshTemp, []byte("value"), 0600))
+	assert.NoError(t, store.index("user", "active"))
+	assert.NoError(t, store.index("user", "expired"))
+	assert.NoError(t, store.index("user", "missing"))
+
+	_, err := store.read(store.fileName("expired"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Act
+	removed, err := store.Cleanup(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	for name, wantExists := range map[string]bool{
+		store.fileName("active"):           true,
+		store.fileName("expired"):          false,
+		store.fileName("legacy_active"):    true,
+		store.fileName("legacy_expired"):   false,
+		filepath.Join(tmpDir, "unrelated"): true,
+		staleTemp:                          false,
+		staleIndexTemp:                     false,
+		freshTemp:                          true,
+	} {
+		_, err := os.Stat(name)
+		assert.Equal(t, wantExists, err == nil, name)
+	}
+	// the IDs of removed sessions are pruned from the subject index
+	ids, err := store.SubjectSessions(context.Background(), "user")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"active"}, ids)
+}
+
+func TestFileSystemStore_Index(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewFileSystemStore(t.TempDir(), 0)
+	assert.NoError(t, store.index("user", "FIRST"))
+	fileName := store.subjectFileName("user")
+	indexedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(fileName, indexedAt, indexedAt))
+
+	// Act
+	err := store.index("user", "FIRST")
+
+	// Assert
+	assert.NoError(t, err)
+	// the index is not written when it already holds the ID
+	info, err := os.Stat(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, indexedAt, info.ModTime())
+	// sessions that no longer exist are only dropped by Cleanup
+	assert.NoError(t, store.index("user", "SECOND"))
+	ids, err := store.SubjectSessions(ctx, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"FIRST", "SECOND"}, ids)
+}
+
+func TestFileSystemStore_ConcurrentWrites(t *testing.T) {
+	// Arrange
+	tmpDir := t.TempDir()
+	store := NewFileSystemStore(tmpDir, 0)
+	values := [][]byte{
+		bytes.Repeat([]byte("a"), 4096),
+		bytes.Repeat([]byte("b"), 8192),
+	}
+
+	// Act
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(value []byte) {
+			defer wg.Done()
+			assert.NoError(t, store.write(store.fileName("shared"), value, time.Now().Add(time.Hour)))
+			assert.NoError(t, store.write(store.fileName(randomID(8)), value, time.Now().Add(time.Hour)))
+		}(values[i%len(values)])
+	}
+	wg.Wait()
+
+	// Assert
+	data, err := store.read(store.fileName("shared"))
+	assert.NoError(t, err)
+	assert.Contains(t, values, data)
+
+	info, err := os.Stat(store.fileName("shared"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(tmpDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 51) // no temporary files are left behind
+}
+
+func TestFileSystemStore_Sharding(t *testing.T) {
+	type testCase struct {
+		options  []FileSystemStoreOption
+		id       string
+		wantPath []string
+	}
+
+	tests := map[string]testCase{
+		"no_sharding": {
+			id:       "ABCDEF",
+			wantPath: []string{"session_ABCDEF"},
+		},
+		"sharding": {
+			options:  []FileSystemStoreOption{WithSharding(2, 2)},
+			id:       "ABCDEF",
+			wantPath: []string{"AB", "CD", "session_ABCDEF"},
+		},
+		"short_id": {
+			options:  []FileSystemStoreOption{WithSharding(3, 2)},
+			id:       "ABC",
+			wantPath: []string{"AB", "session_ABC"},
+		},
+		"invalid_sharding": {
+			options:  []FileSystemStoreOption{WithSharding(2, 0)},
+			id:       "ABCDEF",
+			wantPath: []string{"session_ABCDEF"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			tmpDir := t.TempDir()
+			store := NewFileSystemStore(tmpDir, 0, tc.options...)
+			fileName := store.fileName(tc.id)
+
+			// Act
+			err := store.write(fileName, []byte("value"), time.Now().Add(-time.Second))
+			assert.NoError(t, err)
+			removed, cleanupErr := store.Cleanup(context.Background())
+
+			// Assert
+			assert.Equal(t, filepath.Join(append([]string{tmpDir}, tc.wantPath...)...), fileName)
+			assert.NoError(t, cleanupErr)
+			assert.Equal(t, 1, removed)
+			_, err = os.Stat(fileName)
+			assert.ErrorIs(t, err, os.ErrNotExist)
+		})
+	}
+}
+
+func TestCookieStore_Chunks(t *testing.T) {
+	type testCase struct {
+		value          string
+		maxAge         int
+		requestCookies map[string]string
+		wantCookies    map[string]string
+	}
+
+	tests := map[string]testCase{
+		"single_chunk": {
+			value:  "0123",
+			maxAge: 3600,
+			wantCookies: map[string]string{
+				"session": "0123",
+			},
+		},
+		"many_chunks": {
+			value:  "0123456789",
+			maxAge: 3600,
+			wantCookies: map[string]string{
+				"session":   "0123",
+				"session.1": "4567",
+				"session.2": "89",
+			},
+		},
+		"shrinking_value": {
+			value:  "012345",
+			maxAge: 3600,
+			requestCookies: map[string]string{
+				"session":   "0123",
+				"session.1": "4567",
+				"session.2": "89",
+			},
+			wantCookies: map[string]string{
+				"session":   "0123",
+				"session.1": "45",
+				"session.2": "",
+			},
+		},
+		"expired_session": {
+			value:  "0123456789",
+			maxAge: -1,
+			requestCookies: map[string]string{
+				"session":   "0123",
+				"session.1": "4567",
+				"session.2": "89",
+			},
+			wantCookies: map[string]string{
+				"session":   "",
+				"session.1": "",
+				"session.2": "",
+			},
+		},
+	}
+
+	codec := &stubCodec{
+		encodeFn: func(name string, src any) ([]byte, error) {
+			return []byte(*src.(*string)), nil
+		},
+		decodeFn: func(name string, src []byte, dst any) error {
+			*dst.(*string) = string(src)
+			return nil
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tc.requestCookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			resp := httptest.NewRecorder()
+			proxy := &SessionProxy{
+				Values:   &tc.value,
+				incoming: requestReader(nil, req),
+				outgoing: responseWriter(nil, resp),
+				codecs:   []Codec{codec},
+				options:  &CookieOptions{Name: "session", MaxAge: tc.maxAge},
+			}
+			store := NewCookieStore(WithChunkSize(4))
+
+			// Act
+			err := store.Save(req.Context(), proxy)
+
+			// Assert
+			assert.NoError(t, err)
+			gotCookies := make(map[string]string)
+			for _, cookie := range resp.Result().Cookies() {
+				gotCookies[cookie.Name] = cookie.Value
+			}
+			assert.Equal(t, tc.wantCookies, gotCookies)
+
+			if tc.maxAge > 0 {
+				// read the chunks back in
+				req = httptest.NewRequest(http.MethodGet, "/", nil)
+				for _, cookie := range resp.Result().Cookies() {
+					if cookie.Value != "" {
+						req.AddCookie(cookie)
+					}
+				}
+				proxy = &SessionProxy{
+					Values:   new(string),
+					incoming: requestReader(nil, req),
+					codecs:   []Codec{codec},
+					options:  &CookieOptions{Name: "session"},
+				}
+				cookie, _ := req.Cookie("session")
+				assert.NoError(t, store.Get(req.Context(), proxy, cookie.Value))
+				assert.Equal(t, tc.value, *proxy.Values.(*string))
+			}
+		})
+	}
+}
+
+func TestCookieStore_ChunkLimit(t *testing.T) {
+	// Arrange
+	codec := &stubCodec{
+		encodeFn: func(name string, src any) ([]byte, error) {
+			return []byte(*src.(*string)), nil
+		},
+		decodeFn: func(name string, src []byte, dst any) error {
+			*dst.(*string) = string(src)
+			return nil
+		},
+	}
+	reads := 0
+	proxy := &SessionProxy{
+		Values: new(string),
+		// a client that sends every chunk that is asked for
+		incoming: func(name string) (string, bool) {
+			reads++
+			return "0123", true
+		},
+		outgoing: func(EncodedValue) error { return nil },
+		codecs:   []Codec{codec},
+		options:  &CookieOptions{Name: "session", MaxAge: 3600},
+	}
+	store := NewCookieStore(WithChunkSize(4))
+	tooLong := strings.Repeat("0123", maxChunks+1)
+
+	// Act
+	getErr := store.Get(context.Background(), proxy, "0123")
+	saveErr := store.Save(context.Background(), &SessionProxy{
+		Values:   &tooLong,
+		outgoing: func(EncodedValue) error { return nil },
+		codecs:   []Codec{codec},
+		options:  &CookieOptions{Name: "session", MaxAge: 3600},
+	})
+
+	// Assert
+	assert.NoError(t, getErr)
+	assert.Equal(t, strings.Repeat("0123", maxChunks), *proxy.Values.(*string))
+	assert.Equal(t, maxChunks-1, reads)
+	assert.ErrorIs(t, saveErr, ErrEncodedLengthTooLong)
+}
+
+func TestFileSystemStore_DeleteTouchList(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewFileSystemStore(t.TempDir(), 0, WithSharding(1, 2))
+
+	assert.NoError(t, store.write(store.fileName("ACTIVE"), []byte("value"), time.Now().Add(time.Hour)))
+	assert.NoError(t, store.write(store.fileName("DELETED"), []byte("value"), time.Now().Add(time.Hour)))
+	assert.NoError(t, store.write(store.fileName("EXPIRED"), []byte("value"), time.Now().Add(-time.Second)))
+
+	// Act
+	deleteErr := store.Delete(ctx, "DELETED")
+	touchErr := store.Touch(ctx, "ACTIVE", time.Now().Add(2*time.Hour))
+	touchExpiredErr := store.Touch(ctx, "EXPIRED", time.Now().Add(2*time.Hour))
+	ids, listErr := store.List(ctx)
+
+	// Assert
+	assert.NoError(t, deleteErr)
+	assert.NoError(t, store.Delete(ctx, "DELETED"))
+	assert.NoError(t, touchErr)
+	assert.ErrorIs(t, touchExpiredErr, ErrSessionNotFound)
+	assert.ErrorIs(t, store.Touch(ctx, "MISSING", time.Now()), ErrSessionNotFound)
+	assert.NoError(t, listErr)
+	assert.Equal(t, []string{"ACTIVE"}, ids)
+
+	data, err := os.ReadFile(store.fileName("ACTIVE"))
+	assert.NoError(t, err)
+	expiresAt, value, ok := splitSessionFile(data)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+	assert.True(t, expiresAt.After(time.Now().Add(time.Hour)))
+}
+
+func TestFileSystemStore_InvalidID(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "sessions")
+	victim := filepath.Join(tmpDir, "victim")
+	assert.NoError(t, os.WriteFile(victim, []byte("value"), 0600))
+	store := NewFileSystemStore(root, 0)
+
+	for _, id := range []string{"", "x/../../victim", "../victim", "lower", "AB/CD", "AB.CD"} {
+		// Act
+		deleteErr := store.Delete(ctx, id)
+		touchErr := store.Touch(ctx, id, time.Now().Add(time.Hour))
+		deleteSubjectErr := store.DeleteSubjectSession(ctx, "user", id)
+
+		// Assert
+		assert.ErrorIs(t, deleteErr, ErrInvalidSessionID, id)
+		assert.ErrorIs(t, touchErr, ErrInvalidSessionID, id)
+		assert.ErrorIs(t, deleteSubjectErr, ErrInvalidSessionID, id)
+	}
+	data, err := os.ReadFile(victim)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), data)
+	assert.True(t, validSessionID(randomID(32)))
+}
+
+func TestMemoryStore_DeleteTouchList(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryStore(0)
+	store.sessions["active"] = memorySession{expiresAt: time.Now().Add(time.Hour)}
+	store.sessions["deleted"] = memorySession{expiresAt: time.Now().Add(time.Hour)}
+	store.sessions["expired"] = memorySession{expiresAt: time.Now().Add(-time.Second)}
+	expiresAt := time.Now().Add(2 * time.Hour)
+
+	// Act
+	deleteErr := store.Delete(ctx, "deleted")
+	touchErr := store.Touch(ctx, "active", expiresAt)
+	touchExpiredErr := store.Touch(ctx, "expired", expiresAt)
+	ids, listErr := store.List(ctx)
+
+	// Assert
+	assert.NoError(t, deleteErr)
+	assert.NoError(t, touchErr)
+	assert.ErrorIs(t, touchExpiredErr, ErrSessionNotFound)
+	assert.NoError(t, listErr)
+	assert.Equal(t, []string{"active"}, ids)
+	assert.Equal(t, expiresAt, store.sessions["active"].expiresAt)
+	assert.NoError(t, store.Close())
+}
+
+func TestFileSystemStore_RevokeAll(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	// Arrange
+	ctx := context.Background()
+	store := NewFileSystemStore(t.TempDir(), 0)
+	manager := NewSessionManagerWithOptions[sessionData](
+		CookieOptions{Name: "session", MaxAge: 3600},
+		store,
+		[]Codec{NewCodec(RandomBytes(32))},
+		WithMetadata(),
+	)
+	login := func(subject string) *http.Cookie {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		resp := httptest.NewRecorder()
+		session, err := manager.Get(req)
+		assert.NoError(t, err)
+		session.SetSubject(subject)
+		assert.NoError(t, session.Save(resp, req))
+		return resp.Result().Cookies()[0]
+	}
+	first := login("user-1")
+	second := login("user-1")
+	other := login("user-2")
+
+	// regenerating moves the session in the index
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(second)
+	session, err := manager.Get(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", session.Subject())
+	session.Regenerate()
+	resp := httptest.NewRecorder()
+	assert.NoError(t, session.Save(resp, req))
+	second = resp.Result().Cookies()[0]
+
+	ids, err := store.SubjectSessions(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Len(t, ids, 2)
+	assert.Contains(t, ids, session.ID())
+
+	// Act
+	err = store.RevokeAll(ctx, "user-1")
+
+	// Assert
+	assert.NoError(t, err)
+	// the revoked sessions are replaced with new sessions
+	for cookie, wantSubject := range map[*http.Cookie]string{first: "", second: "", other: "user-2"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		session, err := manager.Get(req)
+		assert.NoError(t, err)
+		assert.Equal(t, wantSubject, session.Subject())
+		assert.Equal(t, wantSubject == "", session.IsNew)
+	}
+	ids, err = store.SubjectSessions(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+}
@@ -5,6 +5,7 @@ import (
 	crand "crypto/rand"
 	"io"
 	"net/http"
+	"sync"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -159,3 +160,39 @@ func (m *mockSessionProxy) Delete() error {
 	args := m.Called()
 	return args.Error(0)
 }
+
+// ----------------------------------------------------------------------------
+
+// pausingRememberMeStore holds the first reads of tokens until they have all
+// been made, so that the requests making them check the same token.
+type pausingRememberMeStore struct {
+	*MemoryRememberMeStore
+	reads sync.WaitGroup
+	mu    sync.Mutex
+	held  int
+}
+
+func newPausingRememberMeStore(reads int) *pausingRememberMeStore {
+	s := &pausingRememberMeStore{
+		MemoryRememberMeStore: NewMemoryRememberMeStore(),
+		held:                  reads,
+	}
+	s.reads.Add(reads)
+	return s
+}
+
+func (s *pausingRememberMeStore) GetRememberMeToken(ctx context.Context, selector string) (RememberMeToken, error) {
+	token, err := s.MemoryRememberMeStore.GetRememberMeToken(ctx, selector)
+
+	s.mu.Lock()
+	hold := s.held > 0
+	if hold {
+		s.held--
+	}
+	s.mu.Unlock()
+	if hold {
+		s.reads.Done()
+		s.reads.Wait()
+	}
+	return token, err
+}
@@ -0,0 +1,132 @@
+package sessions
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Transport carries the encoded session value between the client and the
+// server.
+//
+// Read returns the value the client sent for the name. Write sends the value
+// of the cookie to the client; a cookie with a negative MaxAge asks the client
+// to delete the value. Transports other than CookieTransport may use as much
+// of the cookie as they are able to, such as only its value.
+type Transport interface {
+	Read(r *http.Request, name string) (string, bool)
+	Write(w http.ResponseWriter, cookie *http.Cookie) error
+}
+
+// NamedTransport is implemented by transports that carry a separate value for
+// each name, as cookies do.
+//
+// CookieStore only splits large values across chunks when NamedValues returns
+// true; other transports are given the whole value.
+type NamedTransport interface {
+	Transport
+	NamedValues() bool
+}
+
+// CookieTransport carries the session value in a cookie. It is the default
+// Transport.
+type CookieTransport struct{}
+
+// HeaderTransport carries the session value in request and response headers,
+// for clients that do not use cookies, such as mobile and command-line
+// clients.
+//
+// The value is read from the RequestHeader, after the Scheme if one is set,
+// such as "Authorization: Bearer <value>". The value is written to the
+// ResponseHeader; an empty value asks the client to delete the value.
+type HeaderTransport struct {
+	RequestHeader  string
+	Scheme         string
+	ResponseHeader string
+}
+
+// QueryTransport reads the session value from a query parameter of the
+// request, and writes it to the ResponseHeader.
+//
+// Values in URLs are easily leaked through logs and referrers; prefer
+// HeaderTransport where the client is able to set headers.
+type QueryTransport struct {
+	Param          string
+	ResponseHeader string
+}
+
+var _ NamedTransport = (*CookieTransport)(nil)
+var _ Transport = (*HeaderTransport)(nil)
+var _ Transport = (*QueryTransport)(nil)
+
+// NewBearerTransport returns a HeaderTransport that reads the session value
+// from "Authorization: Bearer <value>" and writes it to the "X-Session-Token"
+// response header.
+func NewBearerTransport() HeaderTransport {
+	return HeaderTransport{
+		RequestHeader:  "Authorization",
+		Scheme:         "Bearer",
+		ResponseHeader: "X-Session-Token",
+	}
+}
+
+func (CookieTransport) Read(r *http.Request, name string) (string, bool) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return c.Value, true
+}
+
+func (CookieTransport) Write(w http.ResponseWriter, cookie *http.Cookie) error {
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+// NamedValues returns true; each cookie is read by its own name.
+func (CookieTransport) NamedValues() bool {
+	return true
+}
+
+func (t HeaderTransport) Read(r *http.Request, _ string) (string, bool) {
+	value := r.Header.Get(t.RequestHeader)
+	if t.Scheme != "" {
+		scheme, token, found := strings.Cut(value, " ")
+		if !found || !strings.EqualFold(scheme, t.Scheme) {
+			return "", false
+		}
+		value = strings.TrimSpace(token)
+	}
+	return value, value != ""
+}
+
+func (t HeaderTransport) Write(w http.ResponseWriter, cookie *http.Cookie) error {
+	return writeResponseHeader(w, t.ResponseHeader, cookie)
+}
+
+func (t QueryTransport) Read(r *http.Request, _ string) (string, bool) {
+	value := r.URL.Query().Get(t.Param)
+	return value, value != ""
+}
+
+func (t QueryTransport) Write(w http.ResponseWriter, cookie *http.Cookie) error {
+	return writeResponseHeader(w, t.ResponseHeader, cookie)
+}
+
+// namedValues reports if the transport carries a separate value for each
+// name; cookies are carried when the transport is nil.
+func namedValues(transport Transport) bool {
+	if transport == nil {
+		return true
+	}
+	named, ok := transport.(NamedTransport)
+	return ok && named.NamedValues()
+}
+
+func writeResponseHeader(w http.ResponseWriter, header string, cookie *http.Cookie) error {
+	if cookie.MaxAge < 0 {
+		w.Header().Set(header, "")
+		return nil
+	}
+	w.Header().Set(header, cookie.Value)
+	return nil
+}
@@ -0,0 +1,189 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransport(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		transport Transport
+		issued    func(resp *http.Response) string
+		send      func(r *http.Request, value string)
+	}
+
+	tests := map[string]testCase{
+		"cookie": {
+			transport: CookieTransport{},
+			issued: func(resp *http.Response) string {
+				return resp.Cookies()[0].Value
+			},
+			send: func(r *http.Request, value string) {
+				r.AddCookie(&http.Cookie{Name: "session", Value: value})
+			},
+		},
+		"bearer": {
+			transport: NewBearerTransport(),
+			issued: func(resp *http.Response) string {
+				return resp.Header.Get("X-Session-Token")
+			},
+			send: func(r *http.Request, value string) {
+				r.Header.Set("Authorization", "Bearer "+value)
+			},
+		},
+		"query": {
+			transport: QueryTransport{Param: "session", ResponseHeader: "X-Session-Token"},
+			issued: func(resp *http.Response) string {
+				return resp.Header.Get("X-Session-Token")
+			},
+			send: func(r *http.Request, value string) {
+				r.URL.RawQuery = url.Values{"session": {value}}.Encode()
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				NewMemoryStore(0),
+				[]Codec{NewCodec(RandomBytes(32))},
+				WithTransport(tc.transport),
+			)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			session, err := manager.Get(req)
+			assert.NoError(t, err)
+			session.Values.Value = "session-value"
+			resp := httptest.NewRecorder()
+			assert.NoError(t, session.Save(resp, req))
+			value := tc.issued(resp.Result())
+			assert.NotEmpty(t, value)
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			tc.send(req, value)
+
+			// Act
+			loaded, err := manager.Get(req)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.False(t, loaded.IsNew)
+			assert.Equal(t, "session-value", loaded.Values.Value)
+
+			resp = httptest.NewRecorder()
+			assert.NoError(t, loaded.Delete(resp, req))
+			assert.Empty(t, tc.issued(resp.Result()))
+		})
+	}
+}
+
+func TestHeaderTransport_Read(t *testing.T) {
+	type testCase struct {
+		header    string
+		wantValue string
+		wantOk    bool
+	}
+
+	tests := map[string]testCase{
+		"bearer":       {header: "Bearer value", wantValue: "value", wantOk: true},
+		"lowercase":    {header: "bearer value", wantValue: "value", wantOk: true},
+		"other_scheme": {header: "Basic value", wantOk: false},
+		"no_scheme":    {header: "value", wantOk: false},
+		"missing":      {wantOk: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+
+			// Act
+			value, ok := NewBearerTransport().Read(req, "session")
+
+			// Assert
+			assert.Equal(t, tc.wantValue, value)
+			assert.Equal(t, tc.wantOk, ok)
+		})
+	}
+}
+
+func TestTransport_ChunkedCookieStore(t *testing.T) {
+	type sessionData struct {
+		Value string
+	}
+
+	type testCase struct {
+		transport Transport
+		send      func(r *http.Request, value string)
+	}
+
+	tests := map[string]testCase{
+		"bearer": {
+			transport: NewBearerTransport(),
+			send: func(r *http.Request, value string) {
+				r.Header.Set("Authorization", "Bearer "+value)
+			},
+		},
+		"query": {
+			transport: QueryTransport{Param: "session", ResponseHeader: "X-Session-Token"},
+			send: func(r *http.Request, value string) {
+				r.URL.RawQuery = url.Values{"session": {value}}.Encode()
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			manager := NewSessionManagerWithOptions[sessionData](
+				CookieOptions{Name: "session", MaxAge: 3600},
+				NewCookieStore(WithChunkSize(100)),
+				[]Codec{NewCodec(RandomBytes(32))},
+				WithTransport(tc.transport),
+			)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tc.send(req, "x")
+
+			// Act
+			done := make(chan error, 1)
+			go func() {
+				_, err := manager.Get(req)
+				done <- err
+			}()
+
+			// Assert
+			select {
+			case err := <-done:
+				assert.Error(t, err)
+			case <-time.After(time.Second):
+				t.Fatal("loading the session did not return")
+			}
+
+			// values are not split for transports without named values
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			session, err := manager.Get(req)
+			assert.NoError(t, err)
+			session.Values.Value = strings.Repeat("v", 500)
+			resp := httptest.NewRecorder()
+			assert.NoError(t, session.Save(resp, req))
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			tc.send(req, resp.Header().Get("X-Session-Token"))
+			loaded, err := manager.Get(req)
+			assert.NoError(t, err)
+			assert.Equal(t, session.Values.Value, loaded.Values.Value)
+		})
+	}
+}